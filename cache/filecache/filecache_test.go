@@ -17,7 +17,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -28,6 +27,7 @@ import (
 	"github.com/gohugoio/hugo/common/hugio"
 	"github.com/gohugoio/hugo/config"
 	"github.com/gohugoio/hugo/helpers"
+	"github.com/gohugoio/hugo/htesting"
 
 	"github.com/gohugoio/hugo/hugofs"
 	"github.com/spf13/afero"
@@ -39,13 +39,13 @@ func TestFileCache(t *testing.T) {
 	t.Parallel()
 	assert := require.New(t)
 
-	tempWorkingDir, err := ioutil.TempDir("", "hugo_filecache_test_work")
+	tempWorkingDir, cleanWorkingDir, err := htesting.CreateTempDir(hugofs.Os, "hugo_filecache_test_work")
 	assert.NoError(err)
-	defer os.Remove(tempWorkingDir)
+	defer cleanWorkingDir()
 
-	tempCacheDir, err := ioutil.TempDir("", "hugo_filecache_test_cache")
+	tempCacheDir, cleanCacheDir, err := htesting.CreateTempDir(hugofs.Os, "hugo_filecache_test_cache")
 	assert.NoError(err)
-	defer os.Remove(tempCacheDir)
+	defer cleanCacheDir()
 
 	osfs := afero.NewOsFs()
 
@@ -293,21 +293,10 @@ func resolveContentPath(sites *hugolib.HugoSites, fs afero.Fs, targetPath string
 // FindArchetype takes a given kind/archetype of content and returns the path
 // to the archetype in the archetype filesystem, blank if none found.
 func findArchetype(ps *helpers.PathSpec, kind, ext string) (outpath string, isDir bool) {
-	fs := ps.BaseFs.Archetypes.Fs
-
-	var pathsToCheck []string
-
-	if kind != "" {
-		pathsToCheck = append(pathsToCheck, kind+ext)
-	}
-	pathsToCheck = append(pathsToCheck, "default"+ext, "default")
-
-	for _, p := range pathsToCheck {
-		fi, err := fs.Stat(p)
-		if err == nil {
-			return p, fi.IsDir()
-		}
+	p, fi, found := ps.BaseFs.Archetype(kind, ext)
+	if !found {
+		return "", false
 	}
 
-	return "", false
+	return p, fi.IsDir()
 }
@@ -0,0 +1,86 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tempdir creates temp dirs for tests. It has no Hugo dependencies,
+// so it can be used in the tests of any package, including hugofs.
+package tempdir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// Deterministic makes Create create directories with predictable names,
+// e.g. "/tmp/hugosym-1", which makes failures depending on the filesystem
+// layout easier to reproduce.
+// It is enabled by setting the HUGO_TESTING_DETERMINISTIC_TEMPDIRS environment
+// variable to a non-empty value.
+var Deterministic = os.Getenv("HUGO_TESTING_DETERMINISTIC_TEMPDIRS") != ""
+
+var counters = struct {
+	sync.Mutex
+	m map[string]int
+}{m: make(map[string]int)}
+
+// Create creates a temp dir in the given filesystem and
+// returns the dirname and a func that removes it when done.
+// See Deterministic.
+func Create(fs afero.Fs, prefix string) (string, func(), error) {
+	var (
+		tempDir string
+		err     error
+	)
+
+	if Deterministic {
+		tempDir, err = createDeterministic(fs, prefix)
+	} else {
+		tempDir, err = afero.TempDir(fs, "", prefix)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, isOsFs := fs.(*afero.OsFs)
+
+	if isOsFs && runtime.GOOS == "darwin" && !strings.HasPrefix(tempDir, "/private") {
+		// To get the entry folder in line with the rest. This its a little bit
+		// mysterious, but so be it.
+		tempDir = "/private" + tempDir
+	}
+
+	return tempDir, func() { fs.RemoveAll(tempDir) }, nil
+}
+
+func createDeterministic(fs afero.Fs, prefix string) (string, error) {
+	counters.Lock()
+	counters.m[prefix]++
+	n := counters.m[prefix]
+	counters.Unlock()
+
+	tempDir := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", prefix, n))
+
+	if err := fs.Mkdir(tempDir, 0700); err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("temp dir %q already exists; remove it or unset HUGO_TESTING_DETERMINISTIC_TEMPDIRS", tempDir)
+		}
+		return "", err
+	}
+
+	return tempDir, nil
+}
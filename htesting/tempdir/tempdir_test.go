@@ -0,0 +1,61 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tempdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	d1, clean1, err := Create(fs, "hugo-test")
+	assert.NoError(err)
+	d2, clean2, err := Create(fs, "hugo-test")
+	assert.NoError(err)
+	assert.NotEqual(d1, d2)
+	clean1()
+	clean2()
+	_, err = fs.Stat(d1)
+	assert.True(os.IsNotExist(err))
+
+	Deterministic = true
+	defer func() {
+		Deterministic = false
+	}()
+
+	d1, clean1, err = Create(fs, "hugo-det")
+	assert.NoError(err)
+	defer clean1()
+	assert.Equal(filepath.Join(os.TempDir(), "hugo-det-1"), d1)
+	fi, err := fs.Stat(d1)
+	assert.NoError(err)
+	assert.True(fi.IsDir())
+
+	d2, clean2, err = Create(fs, "hugo-det")
+	assert.NoError(err)
+	defer clean2()
+	assert.Equal(filepath.Join(os.TempDir(), "hugo-det-2"), d2)
+
+	// Already exists.
+	assert.NoError(fs.Mkdir(filepath.Join(os.TempDir(), "hugo-det-3"), 0700))
+	_, _, err = Create(fs, "hugo-det")
+	assert.Error(err)
+}
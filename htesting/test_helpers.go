@@ -0,0 +1,26 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package htesting
+
+import (
+	"github.com/gohugoio/hugo/htesting/tempdir"
+	"github.com/spf13/afero"
+)
+
+// CreateTempDir creates a temp dir in the given filesystem and
+// returns the dirname and a func that removes it when done.
+// See tempdir.Deterministic.
+func CreateTempDir(fs afero.Fs, prefix string) (string, func(), error) {
+	return tempdir.Create(fs, prefix)
+}
@@ -15,6 +15,8 @@ package hugofs
 
 import (
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -39,18 +41,125 @@ func (f *realFilenameInfo) RealFilename() string {
 // NewBasePathRealFilenameFs returns a new BasePathRealFilenameFs instance
 // using base.
 func NewBasePathRealFilenameFs(base *afero.BasePathFs) *BasePathRealFilenameFs {
-	return &BasePathRealFilenameFs{BasePathFs: base}
+	path, _ := base.RealPath("")
+	return &BasePathRealFilenameFs{BasePathFs: base, path: path}
 }
 
 // BasePathRealFilenameFs is a thin wrapper around afero.BasePathFs that
 // provides the real filename in Stat and LstatIfPossible.
+// Note that afero.BasePathFs only checks that the real filename has the base
+// path as a string prefix, so with a base of /site/public, "../public-secrets"
+// would be let through. Here the base path must be a prefix on whole path
+// elements, anything else is treated as a non existing file.
 type BasePathRealFilenameFs struct {
 	*afero.BasePathFs
+
+	// The cleaned base path.
+	path string
+}
+
+// RealPath returns the given name with the base path prepended, or an error
+// if the name is outside the base path.
+func (b *BasePathRealFilenameFs) RealPath(name string) (string, error) {
+	path, err := b.BasePathFs.RealPath(name)
+	if err != nil {
+		return name, err
+	}
+
+	if path != b.path && !strings.HasPrefix(path, strings.TrimSuffix(b.path, filepathSeparator)+filepathSeparator) {
+		return name, os.ErrNotExist
+	}
+
+	return path, nil
+}
+
+func (b *BasePathRealFilenameFs) checkPath(op, name string) error {
+	if _, err := b.RealPath(name); err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+func (b *BasePathRealFilenameFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := b.checkPath("chtimes", name); err != nil {
+		return err
+	}
+	return b.BasePathFs.Chtimes(name, atime, mtime)
+}
+
+func (b *BasePathRealFilenameFs) Chmod(name string, mode os.FileMode) error {
+	if err := b.checkPath("chmod", name); err != nil {
+		return err
+	}
+	return b.BasePathFs.Chmod(name, mode)
+}
+
+func (b *BasePathRealFilenameFs) Rename(oldname, newname string) error {
+	if err := b.checkPath("rename", oldname); err != nil {
+		return err
+	}
+	if err := b.checkPath("rename", newname); err != nil {
+		return err
+	}
+	return b.BasePathFs.Rename(oldname, newname)
+}
+
+func (b *BasePathRealFilenameFs) RemoveAll(name string) error {
+	if err := b.checkPath("remove_all", name); err != nil {
+		return err
+	}
+	return b.BasePathFs.RemoveAll(name)
+}
+
+func (b *BasePathRealFilenameFs) Remove(name string) error {
+	if err := b.checkPath("remove", name); err != nil {
+		return err
+	}
+	return b.BasePathFs.Remove(name)
+}
+
+func (b *BasePathRealFilenameFs) OpenFile(name string, flag int, mode os.FileMode) (afero.File, error) {
+	if err := b.checkPath("openfile", name); err != nil {
+		return nil, err
+	}
+	return b.BasePathFs.OpenFile(name, flag, mode)
+}
+
+func (b *BasePathRealFilenameFs) Open(name string) (afero.File, error) {
+	if err := b.checkPath("open", name); err != nil {
+		return nil, err
+	}
+	return b.BasePathFs.Open(name)
+}
+
+func (b *BasePathRealFilenameFs) Mkdir(name string, mode os.FileMode) error {
+	if err := b.checkPath("mkdir", name); err != nil {
+		return err
+	}
+	return b.BasePathFs.Mkdir(name, mode)
+}
+
+func (b *BasePathRealFilenameFs) MkdirAll(name string, mode os.FileMode) error {
+	if err := b.checkPath("mkdir", name); err != nil {
+		return err
+	}
+	return b.BasePathFs.MkdirAll(name, mode)
+}
+
+func (b *BasePathRealFilenameFs) Create(name string) (afero.File, error) {
+	if err := b.checkPath("create", name); err != nil {
+		return nil, err
+	}
+	return b.BasePathFs.Create(name)
 }
 
 // Stat returns the os.FileInfo structure describing a given file.  If there is
 // an error, it will be of type *os.PathError.
 func (b *BasePathRealFilenameFs) Stat(name string) (os.FileInfo, error) {
+	if err := b.checkPath("stat", name); err != nil {
+		return nil, err
+	}
+
 	fi, err := b.BasePathFs.Stat(name)
 	if err != nil {
 		return nil, err
@@ -72,13 +181,17 @@ func (b *BasePathRealFilenameFs) Stat(name string) (os.FileInfo, error) {
 // It attempts to use Lstat if supported or defers to the os.  In addition to
 // the FileInfo, a boolean is returned telling whether Lstat was called.
 func (b *BasePathRealFilenameFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if err := b.checkPath("lstat", name); err != nil {
+		return nil, false, err
+	}
 
 	fi, ok, err := b.BasePathFs.LstatIfPossible(name)
 	if err != nil {
 		return nil, false, err
 	}
 
-	if _, ok := fi.(RealFilenameInfo); ok {
+	// Note that ok tells whether Lstat was called, so we must not shadow it.
+	if _, isRealFilenameInfo := fi.(RealFilenameInfo); isRealFilenameInfo {
 		return fi, ok, nil
 	}
 
@@ -0,0 +1,104 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gohugoio/hugo/htesting/tempdir"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasePathRealFilenameFsLstatIfPossible(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestBasePathRealFilenameFsLstatIfPossible as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	d, clean, err := tempdir.Create(Os, "hugo-lstat")
+	assert.NoError(err)
+	defer clean()
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(d, "file.txt"), []byte("some content"), 0755))
+	assert.NoError(os.Symlink(filepath.Join(d, "file.txt"), filepath.Join(d, "symlink.txt")))
+
+	newFs := func(fs afero.Fs, base string) afero.Lstater {
+		return NewBasePathRealFilenameFs(afero.NewBasePathFs(fs, base).(*afero.BasePathFs))
+	}
+
+	for _, test := range []struct {
+		name    string
+		fs      afero.Lstater
+		isLstat bool
+	}{
+		{"Os", newFs(afero.NewOsFs(), d), true},
+		{"No Lstat", newFs(NewNoLstatFs(afero.NewOsFs()), d), false},
+		// The inner filesystem provides real filenames.
+		{"Os, nested", newFs(newFs(afero.NewOsFs(), d).(afero.Fs), "/"), true},
+		{"No Lstat, nested", newFs(NewNoLstatFs(newFs(afero.NewOsFs(), d).(afero.Fs)), "/"), false},
+	} {
+		fi, ok, err := test.fs.LstatIfPossible("symlink.txt")
+		assert.NoError(err, test.name)
+		assert.Equal(test.isLstat, ok, test.name)
+		assert.Equal(test.isLstat, fi.Mode()&os.ModeSymlink != 0, test.name)
+		assert.Equal(filepath.Join(d, "symlink.txt"), fi.(RealFilenameInfo).RealFilename(), test.name)
+	}
+}
+
+func TestBasePathRealFilenameFsOutsideBase(t *testing.T) {
+	assert := require.New(t)
+
+	fs := afero.NewMemMapFs()
+	base := filepath.FromSlash("/site/public")
+	assert.NoError(afero.WriteFile(fs, filepath.Join(base, "index.html"), []byte("public"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("/site/public-secrets/key.txt"), []byte("secret"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("/site/config.toml"), []byte("config"), 0755))
+
+	bfs := NewBasePathRealFilenameFs(afero.NewBasePathFs(fs, base).(*afero.BasePathFs))
+
+	for _, name := range []string{"index.html", filepath.FromSlash("sub/../index.html"), filepath.FromSlash("/index.html")} {
+		filename, err := bfs.RealPath(name)
+		assert.NoError(err, name)
+		assert.Equal(filepath.Join(base, "index.html"), filename, name)
+		_, err = bfs.Stat(name)
+		assert.NoError(err, name)
+	}
+
+	for _, name := range []string{
+		filepath.FromSlash("../public-secrets/key.txt"),
+		filepath.FromSlash("../config.toml"),
+		filepath.FromSlash("../../site/config.toml"),
+	} {
+		_, err := bfs.RealPath(name)
+		assert.Equal(os.ErrNotExist, err, name)
+		_, err = bfs.Stat(name)
+		assert.True(os.IsNotExist(err), name)
+		_, _, err = bfs.LstatIfPossible(name)
+		assert.True(os.IsNotExist(err), name)
+		_, err = bfs.Open(name)
+		assert.True(os.IsNotExist(err), name)
+		assert.True(os.IsNotExist(bfs.Remove(name)), name)
+		assert.True(os.IsNotExist(afero.WriteFile(bfs, name, []byte("overwritten"), 0755)), name)
+	}
+
+	b, err := afero.ReadFile(fs, filepath.FromSlash("/site/public-secrets/key.txt"))
+	assert.NoError(err)
+	assert.Equal("secret", string(b))
+}
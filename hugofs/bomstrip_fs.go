@@ -0,0 +1,112 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs = (*bomStripFs)(nil)
+
+	byteOrderMarks = [][]byte{
+		{0xEF, 0xBB, 0xBF}, // UTF-8
+		{0xFE, 0xFF},       // UTF-16 (BE)
+		{0xFF, 0xFE},       // UTF-16 (LE)
+	}
+)
+
+type bomStripFs struct {
+	afero.Fs
+}
+
+// NewBOMStripFs creates a new filesystem that removes any leading UTF-8 or
+// UTF-16 byte order mark (BOM) when reading files opened for reading.
+// Note that only Read is affected; Stat will still report the size of the file
+// including the BOM.
+func NewBOMStripFs(fs afero.Fs) afero.Fs {
+	return &bomStripFs{Fs: fs}
+}
+
+func (fs *bomStripFs) Open(name string) (afero.File, error) {
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &bomStripFile{File: f}, nil
+}
+
+func (fs *bomStripFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil || isWrite(flag) {
+		return f, err
+	}
+	return &bomStripFile{File: f}, nil
+}
+
+func (fs *bomStripFs) Name() string {
+	return "bomStripFs"
+}
+
+type bomStripFile struct {
+	afero.File
+
+	checked bool
+
+	// Bytes read from the start of the file that are not part of a BOM.
+	pending []byte
+}
+
+func (f *bomStripFile) Read(p []byte) (int, error) {
+	if !f.checked {
+		f.checked = true
+		start := make([]byte, 3)
+		n, err := io.ReadFull(f.File, start)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return 0, err
+		}
+		start = start[:n]
+		for _, bom := range byteOrderMarks {
+			if bytes.HasPrefix(start, bom) {
+				start = start[len(bom):]
+				break
+			}
+		}
+		f.pending = start
+	}
+
+	if len(f.pending) > 0 {
+		n := copy(p, f.pending)
+		f.pending = f.pending[n:]
+		return n, nil
+	}
+
+	return f.File.Read(p)
+}
+
+// Seek sets the offset in the underlying file. Seeking to the start of the
+// file will strip any BOM on the next Read.
+func (f *bomStripFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.File.Seek(offset, whence)
+	if err != nil {
+		return n, err
+	}
+	f.pending = nil
+	f.checked = n != 0
+	return n, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBOMStripFs(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+	bfs := NewBOMStripFs(fs)
+
+	for _, test := range []struct {
+		content  string
+		expected string
+	}{
+		{"\xEF\xBB\xBFtitle = \"Hugo\"", "title = \"Hugo\""},
+		{"\xFE\xFFab", "ab"},
+		{"\xFF\xFEab", "ab"},
+		{"title = \"Hugo\"", "title = \"Hugo\""},
+		{"\xEF\xBB", "\xEF\xBB"},
+		{"a", "a"},
+		{"", ""},
+	} {
+		assert.NoError(afero.WriteFile(fs, "data.toml", []byte(test.content), 0755))
+
+		b, err := afero.ReadFile(bfs, "data.toml")
+		assert.NoError(err)
+		assert.Equal(test.expected, string(b))
+
+		f, err := bfs.OpenFile("data.toml", os.O_RDONLY, 0)
+		assert.NoError(err)
+		b, err = ioutil.ReadAll(f)
+		assert.NoError(err)
+		assert.Equal(test.expected, string(b))
+
+		// Rewind and read again.
+		_, err = f.Seek(0, io.SeekStart)
+		assert.NoError(err)
+		b, err = ioutil.ReadAll(f)
+		assert.NoError(err)
+		assert.Equal(test.expected, string(b))
+		f.Close()
+
+		// Not touched by the filesystem.
+		b, err = afero.ReadFile(fs, "data.toml")
+		assert.NoError(err)
+		assert.Equal(test.content, string(b))
+	}
+
+	// Files opened for writing are not decorated.
+	f, err := bfs.OpenFile("data.toml", os.O_RDWR, 0755)
+	assert.NoError(err)
+	_, ok := f.(*bomStripFile)
+	assert.False(ok)
+	f.Close()
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs = (*byteCountFs)(nil)
+	_ Reseter  = (*ByteCounter)(nil)
+)
+
+// ByteCounter counts the bytes read through a filesystem created with
+// NewByteCountFs. It is safe for concurrent use.
+type ByteCounter struct {
+	n int64
+}
+
+// Total returns the number of bytes read so far.
+func (c *ByteCounter) Total() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// Reset sets the count to zero.
+func (c *ByteCounter) Reset() {
+	atomic.StoreInt64(&c.n, 0)
+}
+
+func (c *ByteCounter) add(n int) {
+	atomic.AddInt64(&c.n, int64(n))
+}
+
+type byteCountFs struct {
+	afero.Fs
+	counter *ByteCounter
+}
+
+// NewByteCountFs creates a new filesystem that counts the bytes read from the
+// files opened through it, e.g. to find expensive sources in a build.
+func NewByteCountFs(fs afero.Fs) (afero.Fs, *ByteCounter) {
+	counter := &ByteCounter{}
+	return &byteCountFs{Fs: fs, counter: counter}, counter
+}
+
+func (fs *byteCountFs) Open(name string) (afero.File, error) {
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &byteCountFile{File: f, counter: fs.counter}, nil
+}
+
+func (fs *byteCountFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &byteCountFile{File: f, counter: fs.counter}, nil
+}
+
+func (fs *byteCountFs) Name() string {
+	return "byteCountFs"
+}
+
+type byteCountFile struct {
+	afero.File
+	counter *ByteCounter
+}
+
+func (f *byteCountFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.counter.add(n)
+	return n, err
+}
+
+func (f *byteCountFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.counter.add(n)
+	return n, err
+}
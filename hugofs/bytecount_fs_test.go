@@ -0,0 +1,66 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteCountFs(t *testing.T) {
+	assert := require.New(t)
+	mfs := afero.NewMemMapFs()
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(afero.WriteFile(mfs, fmt.Sprintf("file%d.txt", i), []byte(strings.Repeat("a", 100)), 0755))
+	}
+
+	fs, counter := NewByteCountFs(mfs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b, err := afero.ReadFile(fs, fmt.Sprintf("file%d.txt", i))
+			assert.NoError(err)
+			assert.Len(b, 100)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(int64(1000), counter.Total())
+
+	f, err := fs.OpenFile("file0.txt", os.O_RDONLY, 0)
+	assert.NoError(err)
+	b := make([]byte, 10)
+	_, err = f.ReadAt(b, 50)
+	assert.NoError(err)
+	f.Close()
+	assert.Equal(int64(1010), counter.Total())
+
+	// Stat does not count.
+	_, err = fs.Stat("file1.txt")
+	assert.NoError(err)
+	assert.Equal(int64(1010), counter.Total())
+
+	counter.Reset()
+	assert.Equal(int64(0), counter.Total())
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs      = (*CountingFs)(nil)
+	_ afero.Lstater = (*CountingFs)(nil)
+	_ Reseter       = (*CountingFs)(nil)
+)
+
+// FsCounts holds the number of operations done on a CountingFs.
+type FsCounts struct {
+	// The name given to NewCountingFs.
+	Name string
+
+	// Calls to Open and OpenFile.
+	Opens int64
+
+	// Calls to Stat and LstatIfPossible.
+	Stats int64
+
+	// Calls to Readdir and Readdirnames on the opened files.
+	Readdirs int64
+}
+
+// CountingFs is a filesystem that counts the calls to Open, Stat and Readdir,
+// e.g. to find out which filesystem layer a build spends its time in.
+// It is safe for concurrent use.
+type CountingFs struct {
+	afero.Fs
+	name string
+
+	opens    int64
+	stats    int64
+	readdirs int64
+}
+
+// NewCountingFs creates a new CountingFs on top of fs. The name is used to
+// tell the layers apart, e.g. "project".
+func NewCountingFs(fs afero.Fs, name string) *CountingFs {
+	return &CountingFs{Fs: fs, name: name}
+}
+
+// Counts returns the number of operations done so far.
+func (fs *CountingFs) Counts() FsCounts {
+	return FsCounts{
+		Name:     fs.name,
+		Opens:    atomic.LoadInt64(&fs.opens),
+		Stats:    atomic.LoadInt64(&fs.stats),
+		Readdirs: atomic.LoadInt64(&fs.readdirs),
+	}
+}
+
+// Reset sets all the counters to zero.
+func (fs *CountingFs) Reset() {
+	atomic.StoreInt64(&fs.opens, 0)
+	atomic.StoreInt64(&fs.stats, 0)
+	atomic.StoreInt64(&fs.readdirs, 0)
+}
+
+func (fs *CountingFs) Stat(name string) (os.FileInfo, error) {
+	atomic.AddInt64(&fs.stats, 1)
+	return fs.Fs.Stat(name)
+}
+
+// LstatIfPossible returns the os.FileInfo structure describing a given file.
+// It attempts to use Lstat if supported or defers to the os.  In addition to
+// the FileInfo, a boolean is returned telling whether Lstat was called.
+func (fs *CountingFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	atomic.AddInt64(&fs.stats, 1)
+
+	if ls, ok := fs.Fs.(afero.Lstater); ok {
+		return ls.LstatIfPossible(name)
+	}
+
+	fi, err := fs.Fs.Stat(name)
+	return fi, false, err
+}
+
+func (fs *CountingFs) Open(name string) (afero.File, error) {
+	atomic.AddInt64(&fs.opens, 1)
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f, fs: fs}, nil
+}
+
+func (fs *CountingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	atomic.AddInt64(&fs.opens, 1)
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f, fs: fs}, nil
+}
+
+func (fs *CountingFs) Name() string {
+	return fs.name
+}
+
+type countingFile struct {
+	afero.File
+	fs *CountingFs
+}
+
+func (f *countingFile) Readdir(count int) ([]os.FileInfo, error) {
+	atomic.AddInt64(&f.fs.readdirs, 1)
+	return f.File.Readdir(count)
+}
+
+func (f *countingFile) Readdirnames(count int) ([]string, error) {
+	atomic.AddInt64(&f.fs.readdirs, 1)
+	return f.File.Readdirnames(count)
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountingFs(t *testing.T) {
+	assert := require.New(t)
+	base := afero.NewMemMapFs()
+
+	for _, filename := range []string{"a.txt", "b/c.txt", "b/d.txt"} {
+		assert.NoError(afero.WriteFile(base, filepath.FromSlash(filename), []byte("content"), 0755))
+	}
+
+	fs := NewCountingFs(base, "project")
+	assert.Equal("project", fs.Name())
+
+	_, err := fs.Stat("a.txt")
+	assert.NoError(err)
+	_, _, err = fs.LstatIfPossible("a.txt")
+	assert.NoError(err)
+	_, err = fs.Stat("nope.txt")
+	assert.True(os.IsNotExist(err))
+
+	_, err = afero.ReadFile(fs, "a.txt")
+	assert.NoError(err)
+
+	dir, err := fs.Open("b")
+	assert.NoError(err)
+	_, err = dir.Readdir(1)
+	assert.NoError(err)
+	_, err = dir.Readdirnames(-1)
+	assert.NoError(err)
+	dir.Close()
+
+	f, err := fs.OpenFile("e.txt", os.O_CREATE|os.O_WRONLY, 0755)
+	assert.NoError(err)
+	f.Close()
+
+	assert.Equal(FsCounts{Name: "project", Opens: 3, Stats: 3, Readdirs: 2}, fs.Counts())
+
+	fs.Reset()
+	assert.Equal(FsCounts{Name: "project"}, fs.Counts())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				fs.Stat(fmt.Sprintf("file%d.txt", j))
+				if f, err := fs.Open("a.txt"); err == nil {
+					f.Close()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(FsCounts{Name: "project", Opens: 100, Stats: 100}, fs.Counts())
+}
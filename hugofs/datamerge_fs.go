@@ -0,0 +1,275 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gohugoio/hugo/parser"
+	"github.com/gohugoio/hugo/parser/metadecoders"
+	"github.com/spf13/afero"
+	"github.com/spf13/afero/mem"
+)
+
+var _ afero.Fs = (*dataMergeFs)(nil)
+
+type dataMergeFs struct {
+	afero.Fs
+
+	// The layers, highest precedence first.
+	layers []string
+}
+
+// NewDataMergeFs creates a new read-only filesystem on top of fs where a JSON,
+// TOML or YAML data file found on the same path relative to more than one of
+// the given layer directories, e.g. the virtual roots of a RootMappingFs, is
+// deep-merged into one: The file in the layer with the highest precedence,
+// the first, is replaced with a file holding the merged data, where its values
+// win, and the files in the other layers are hidden.
+// Files that do not hold a map, e.g. a JSON array, are not merged; the one
+// in the first layer wins.
+func NewDataMergeFs(fs afero.Fs, layers []string) afero.Fs {
+	cleaned := make([]string, len(layers))
+	for i, layer := range layers {
+		cleaned[i] = filepath.Clean(layer)
+	}
+	return &dataMergeFs{Fs: afero.NewReadOnlyFs(fs), layers: cleaned}
+}
+
+// layerOf returns the index of the layer name belongs to and the path
+// relative to that layer, or -1 if none.
+func (fs *dataMergeFs) layerOf(name string) (int, string) {
+	name = strings.TrimPrefix(filepath.Clean(name), filepathSeparator)
+	for i, layer := range fs.layers {
+		if strings.HasPrefix(name, layer+filepathSeparator) {
+			return i, strings.TrimPrefix(name, layer+filepathSeparator)
+		}
+	}
+	return -1, ""
+}
+
+func mergeFormat(name string) metadecoders.Format {
+	switch f := metadecoders.FormatFromString(filepath.Ext(name)); f {
+	case metadecoders.JSON, metadecoders.TOML, metadecoders.YAML:
+		return f
+	}
+	return ""
+}
+
+// filesFor returns the filenames of the data file name in its own and all
+// the layers below it, or nil if it is not a mergeable data file.
+func (fs *dataMergeFs) filesFor(name string) []string {
+	if mergeFormat(name) == "" {
+		return nil
+	}
+
+	i, rel := fs.layerOf(name)
+	if i == -1 {
+		return nil
+	}
+
+	var filenames []string
+	for _, layer := range fs.layers[i:] {
+		filename := filepath.Join(layer, rel)
+		if fi, err := fs.Fs.Stat(filename); err == nil && !fi.IsDir() {
+			filenames = append(filenames, filename)
+		}
+	}
+
+	return filenames
+}
+
+// isShadowed reports whether name is a mergeable data file that also exists in
+// a layer with higher precedence.
+func (fs *dataMergeFs) isShadowed(name string) bool {
+	if mergeFormat(name) == "" {
+		return false
+	}
+
+	i, rel := fs.layerOf(name)
+	for _, layer := range fs.layers[:i+1] {
+		filename := filepath.Join(layer, rel)
+		if filename == filepath.Clean(strings.TrimPrefix(name, filepathSeparator)) {
+			return false
+		}
+		if fi, err := fs.Fs.Stat(filename); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// merged returns the merged content of the given data files, highest
+// precedence first.
+func (fs *dataMergeFs) merged(filenames []string) ([]byte, error) {
+	format := mergeFormat(filenames[0])
+
+	var result map[string]interface{}
+
+	for i, filename := range filenames {
+		b, err := afero.ReadFile(fs.Fs, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := metadecoders.Default.Unmarshal(b, mergeFormat(filename))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			if i == 0 {
+				// Not a map, nothing to merge.
+				return b, nil
+			}
+			continue
+		}
+
+		if result == nil {
+			result = m
+		} else {
+			deepMerge(result, m)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := parser.InterfaceToConfig(result, format, &buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// deepMerge adds any key in src missing in dst to dst, recursively for
+// nested maps. Values already in dst win.
+func deepMerge(dst, src map[string]interface{}) {
+	for k, sv := range src {
+		dv, found := dst[k]
+		if !found {
+			dst[k] = sv
+			continue
+		}
+		dm, ok1 := dv.(map[string]interface{})
+		sm, ok2 := sv.(map[string]interface{})
+		if ok1 && ok2 {
+			deepMerge(dm, sm)
+		}
+	}
+}
+
+func (fs *dataMergeFs) notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (fs *dataMergeFs) Stat(name string) (os.FileInfo, error) {
+	if fs.isShadowed(name) {
+		return nil, fs.notExist("stat", name)
+	}
+
+	fi, err := fs.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if filenames := fs.filesFor(name); len(filenames) > 1 {
+		b, err := fs.merged(filenames)
+		if err != nil {
+			return nil, err
+		}
+		return &dataMergeFileInfo{FileInfo: fi, size: int64(len(b))}, nil
+	}
+
+	return fi, nil
+}
+
+func (fs *dataMergeFs) Open(name string) (afero.File, error) {
+	if fs.isShadowed(name) {
+		return nil, fs.notExist("open", name)
+	}
+
+	if filenames := fs.filesFor(name); len(filenames) > 1 {
+		fi, err := fs.Fs.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := fs.merged(filenames)
+		if err != nil {
+			return nil, err
+		}
+
+		data := mem.CreateFile(name)
+		mem.SetMode(data, fi.Mode())
+		mem.SetModTime(data, fi.ModTime())
+		f := mem.NewFileHandle(data)
+		if _, err := f.Write(b); err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(0, 0); err != nil {
+			return nil, err
+		}
+
+		return mem.NewReadOnlyFileHandle(data), nil
+	}
+
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataMergeFile{File: f, fs: fs, dirname: name}, nil
+}
+
+func (fs *dataMergeFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if isWrite(flag) {
+		return fs.Fs.OpenFile(name, flag, perm)
+	}
+	return fs.Open(name)
+}
+
+func (fs *dataMergeFs) Name() string {
+	return "dataMergeFs"
+}
+
+type dataMergeFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (fi *dataMergeFileInfo) Size() int64 {
+	return fi.size
+}
+
+type dataMergeFile struct {
+	afero.File
+	fs      *dataMergeFs
+	dirname string
+}
+
+// Readdir works as in os.File, but any shadowed data file is skipped.
+func (f *dataMergeFile) Readdir(count int) ([]os.FileInfo, error) {
+	return filteredReaddir(f.File, count, func(fi os.FileInfo) bool {
+		return fi.IsDir() || !f.fs.isShadowed(filepath.Join(f.dirname, fi.Name()))
+	})
+}
+
+// Readdirnames works as in os.File, but any shadowed data file is skipped.
+func (f *dataMergeFile) Readdirnames(count int) ([]string, error) {
+	return readdirnames(f.Readdir(count))
+}
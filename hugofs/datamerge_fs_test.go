@@ -0,0 +1,106 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/gohugoio/hugo/parser/metadecoders"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataMergeFs(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	write := func(filename, content string) {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte(content), 0755))
+	}
+
+	write("project/site.toml", `
+title = "Project"
+[params]
+color = "red"
+[params.social]
+twitter = "project"
+`)
+	write("project/project.toml", `a = "b"`)
+	write("theme/site.toml", `
+title = "Theme"
+author = "Theme Author"
+[params]
+color = "blue"
+size = 32
+[params.social]
+twitter = "theme"
+github = "theme"
+`)
+	write("theme/theme.toml", `c = "d"`)
+
+	mfs := NewDataMergeFs(fs, []string{"project", "theme"})
+
+	read := func(filename string) map[string]interface{} {
+		b, err := afero.ReadFile(mfs, filepath.FromSlash(filename))
+		assert.NoError(err)
+		m, err := metadecoders.Default.UnmarshalToMap(b, metadecoders.TOML)
+		assert.NoError(err)
+		return m
+	}
+
+	m := read("project/site.toml")
+	assert.Equal("Project", m["title"])
+	assert.Equal("Theme Author", m["author"])
+	params := m["params"].(map[string]interface{})
+	assert.Equal("red", params["color"])
+	assert.Equal(int64(32), params["size"])
+	social := params["social"].(map[string]interface{})
+	assert.Equal("project", social["twitter"])
+	assert.Equal("theme", social["github"])
+
+	fi, err := mfs.Stat(filepath.FromSlash("project/site.toml"))
+	assert.NoError(err)
+	b, err := afero.ReadFile(mfs, filepath.FromSlash("project/site.toml"))
+	assert.NoError(err)
+	assert.Equal(int64(len(b)), fi.Size())
+
+	// Distinct files are left untouched.
+	assert.Equal("b", read("project/project.toml")["a"])
+	assert.Equal("d", read("theme/theme.toml")["c"])
+
+	// The shadowed file is hidden.
+	_, err = mfs.Stat(filepath.FromSlash("theme/site.toml"))
+	assert.True(os.IsNotExist(err))
+	_, err = mfs.Open(filepath.FromSlash("theme/site.toml"))
+	assert.True(os.IsNotExist(err))
+
+	readdirnames := func(dirname string) []string {
+		f, err := mfs.Open(dirname)
+		assert.NoError(err)
+		defer f.Close()
+		names, err := f.Readdirnames(-1)
+		assert.NoError(err)
+		sort.Strings(names)
+		return names
+	}
+
+	assert.Equal([]string{"project.toml", "site.toml"}, readdirnames("project"))
+	assert.Equal([]string{"theme.toml"}, readdirnames("theme"))
+
+	// Read-only.
+	assert.Error(afero.WriteFile(mfs, filepath.FromSlash("project/new.toml"), []byte("e = 1"), 0755))
+}
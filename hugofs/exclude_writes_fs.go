@@ -0,0 +1,154 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs   = (*excludeWritesFs)(nil)
+	_ afero.File = (*discardFile)(nil)
+)
+
+type excludeWritesFs struct {
+	afero.Fs
+	globs ignoreGlobs
+}
+
+// NewExcludeWritesFs creates a new filesystem that silently drops any file
+// created or opened for writing that matches one of the given glob patterns.
+// Such files are never written to fs; the caller gets a file that discards
+// everything written to it.
+// The patterns work as in NewGlobIgnoreFs.
+func NewExcludeWritesFs(fs afero.Fs, patterns []string) afero.Fs {
+	return &excludeWritesFs{Fs: fs, globs: compileIgnoreGlobs(patterns)}
+}
+
+func (fs *excludeWritesFs) Create(name string) (afero.File, error) {
+	if fs.globs.match(name) {
+		return &discardFile{name: name, mode: 0666}, nil
+	}
+	return fs.Fs.Create(name)
+}
+
+func (fs *excludeWritesFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if isWrite(flag) && fs.globs.match(name) {
+		return &discardFile{name: name, mode: perm}, nil
+	}
+	return fs.Fs.OpenFile(name, flag, perm)
+}
+
+func (fs *excludeWritesFs) Name() string {
+	return "excludeWritesFs"
+}
+
+// discardFile is a file that throws away anything written to it.
+type discardFile struct {
+	name string
+	mode os.FileMode
+	size int64
+}
+
+func (f *discardFile) Close() error {
+	return nil
+}
+
+func (f *discardFile) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (f *discardFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, io.EOF
+}
+
+func (f *discardFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+func (f *discardFile) Write(p []byte) (int, error) {
+	f.size += int64(len(p))
+	return len(p), nil
+}
+
+func (f *discardFile) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > f.size {
+		f.size = end
+	}
+	return len(p), nil
+}
+
+func (f *discardFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *discardFile) Name() string {
+	return f.name
+}
+
+func (f *discardFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: errNoOp}
+}
+
+func (f *discardFile) Readdirnames(n int) ([]string, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: errNoOp}
+}
+
+func (f *discardFile) Stat() (os.FileInfo, error) {
+	return &discardFileInfo{name: filepath.Base(f.name), mode: f.mode, size: f.size}, nil
+}
+
+func (f *discardFile) Sync() error {
+	return nil
+}
+
+func (f *discardFile) Truncate(size int64) error {
+	f.size = size
+	return nil
+}
+
+type discardFileInfo struct {
+	name string
+	mode os.FileMode
+	size int64
+}
+
+func (fi *discardFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *discardFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *discardFileInfo) Mode() os.FileMode {
+	return fi.mode
+}
+
+func (fi *discardFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (fi *discardFileInfo) IsDir() bool {
+	return false
+}
+
+func (fi *discardFileInfo) Sys() interface{} {
+	return nil
+}
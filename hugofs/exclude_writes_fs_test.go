@@ -0,0 +1,62 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExcludeWritesFs(t *testing.T) {
+	assert := require.New(t)
+	mfs := afero.NewMemMapFs()
+	fs := NewExcludeWritesFs(mfs, []string{"*.map", "/drafts"})
+
+	for _, test := range []struct {
+		filename string
+		excluded bool
+	}{
+		{"main.js", false},
+		{"main.js.map", true},
+		{"js/main.js.map", true},
+		{"drafts/a.html", true},
+		{"blog/drafts/a.html", false},
+	} {
+		filename := filepath.FromSlash(test.filename)
+		assert.NoError(afero.WriteFile(fs, filename, []byte("content"), 0755), test.filename)
+		_, err := mfs.Stat(filename)
+		assert.Equal(test.excluded, os.IsNotExist(err), test.filename)
+	}
+
+	f, err := fs.Create("main.css.map")
+	assert.NoError(err)
+	n, err := f.WriteString("content")
+	assert.NoError(err)
+	assert.Equal(7, n)
+	fi, err := f.Stat()
+	assert.NoError(err)
+	assert.Equal("main.css.map", fi.Name())
+	assert.Equal(int64(7), fi.Size())
+	assert.NoError(f.Close())
+
+	// Reading is not affected.
+	assert.NoError(afero.WriteFile(mfs, "existing.map", []byte("content"), 0755))
+	b, err := afero.ReadFile(fs, "existing.map")
+	assert.NoError(err)
+	assert.Equal("content", string(b))
+}
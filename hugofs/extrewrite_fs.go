@@ -0,0 +1,171 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var _ afero.Fs = (*extRewriteFs)(nil)
+
+type extRewriteFs struct {
+	afero.Fs
+	from string
+	to   string
+}
+
+// NewExtRewriteFs creates a new read-only filesystem where every file with
+// the extension from, e.g. ".scss", is also available with the extension to,
+// e.g. ".css", so a lookup of "main.css" will find "main.scss". Both names are
+// included in directory listings. A real file with the to extension always
+// wins.
+func NewExtRewriteFs(fs afero.Fs, from, to string) afero.Fs {
+	return &extRewriteFs{Fs: afero.NewReadOnlyFs(fs), from: from, to: to}
+}
+
+// source returns the filename with the from extension for the given filename
+// with the to extension, or an empty string if name has another extension.
+func (fs *extRewriteFs) source(name string) string {
+	if filepath.Ext(name) != fs.to {
+		return ""
+	}
+	return strings.TrimSuffix(name, fs.to) + fs.from
+}
+
+func (fs *extRewriteFs) Stat(name string) (os.FileInfo, error) {
+	fi, err := fs.Fs.Stat(name)
+	if err == nil || !os.IsNotExist(err) {
+		return fi, err
+	}
+
+	source := fs.source(name)
+	if source == "" {
+		return nil, err
+	}
+
+	sfi, serr := fs.Fs.Stat(source)
+	if serr != nil || sfi.IsDir() {
+		return nil, err
+	}
+
+	return &extRewriteFileInfo{FileInfo: sfi, name: filepath.Base(name)}, nil
+}
+
+func (fs *extRewriteFs) Open(name string) (afero.File, error) {
+	f, err := fs.Fs.Open(name)
+	if err == nil {
+		return &extRewriteFile{File: f, fs: fs}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	source := fs.source(name)
+	if source == "" {
+		return nil, err
+	}
+
+	if sfi, serr := fs.Fs.Stat(source); serr != nil || sfi.IsDir() {
+		return nil, err
+	}
+
+	sf, serr := fs.Fs.Open(source)
+	if serr != nil {
+		return nil, serr
+	}
+
+	return &extRewriteFile{File: sf, fs: fs, name: name}, nil
+}
+
+func (fs *extRewriteFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if isWrite(flag) {
+		return fs.Fs.OpenFile(name, flag, perm)
+	}
+	return fs.Open(name)
+}
+
+func (fs *extRewriteFs) Name() string {
+	return "extRewriteFs"
+}
+
+type extRewriteFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *extRewriteFileInfo) Name() string {
+	return fi.name
+}
+
+type extRewriteFile struct {
+	afero.File
+	fs *extRewriteFs
+
+	// Set if opened with the rewritten name.
+	name string
+
+	dir bufferedDir
+}
+
+func (f *extRewriteFile) Name() string {
+	if f.name != "" {
+		return f.name
+	}
+	return f.File.Name()
+}
+
+func (f *extRewriteFile) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil || f.name == "" {
+		return fi, err
+	}
+	return &extRewriteFileInfo{FileInfo: fi, name: filepath.Base(f.name)}, nil
+}
+
+// Readdir works as in os.File, but every file with the from extension is
+// followed by an entry with the to extension, unless that file exists.
+func (f *extRewriteFile) Readdir(count int) ([]os.FileInfo, error) {
+	return f.dir.readdir(f.File, count, f.rewrite)
+}
+
+func (f *extRewriteFile) rewrite(fis []os.FileInfo) []os.FileInfo {
+	var result []os.FileInfo
+
+	names := make(map[string]bool)
+	for _, fi := range fis {
+		names[fi.Name()] = true
+	}
+
+	for _, fi := range fis {
+		result = append(result, fi)
+		if fi.IsDir() || filepath.Ext(fi.Name()) != f.fs.from {
+			continue
+		}
+		name := strings.TrimSuffix(fi.Name(), f.fs.from) + f.fs.to
+		if !names[name] {
+			result = append(result, &extRewriteFileInfo{FileInfo: fi, name: name})
+		}
+	}
+
+	return result
+}
+
+// Readdirnames works as in os.File, see Readdir.
+func (f *extRewriteFile) Readdirnames(count int) ([]string, error) {
+	return readdirnames(f.Readdir(count))
+}
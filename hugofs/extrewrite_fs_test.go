@@ -0,0 +1,104 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtRewriteFs(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for filename, content := range map[string]string{
+		"css/main.scss":   "main scss",
+		"css/other.scss":  "other scss",
+		"css/other.css":   "other css",
+		"css/plain.txt":   "plain",
+		"css/dir.scss/a":  "a",
+		"css/typo.css.no": "typo",
+	} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte(content), 0755))
+	}
+
+	rfs := NewExtRewriteFs(fs, ".scss", ".css")
+
+	main := filepath.FromSlash("css/main.css")
+	fi, err := rfs.Stat(main)
+	assert.NoError(err)
+	assert.Equal("main.css", fi.Name())
+	assert.Equal(int64(len("main scss")), fi.Size())
+
+	b, err := afero.ReadFile(rfs, main)
+	assert.NoError(err)
+	assert.Equal("main scss", string(b))
+
+	f, err := rfs.Open(main)
+	assert.NoError(err)
+	assert.Equal(main, f.Name())
+	fi, err = f.Stat()
+	assert.NoError(err)
+	assert.Equal("main.css", fi.Name())
+	f.Close()
+
+	// The source is still there.
+	b, err = afero.ReadFile(rfs, filepath.FromSlash("css/main.scss"))
+	assert.NoError(err)
+	assert.Equal("main scss", string(b))
+
+	// A real file wins.
+	b, err = afero.ReadFile(rfs, filepath.FromSlash("css/other.css"))
+	assert.NoError(err)
+	assert.Equal("other css", string(b))
+
+	for _, name := range []string{"css/nope.css", "css/dir.css", "css/plain.css"} {
+		_, err = rfs.Stat(filepath.FromSlash(name))
+		assert.True(os.IsNotExist(err), name)
+		_, err = rfs.Open(filepath.FromSlash(name))
+		assert.True(os.IsNotExist(err), name)
+	}
+
+	dir, err := rfs.Open("css")
+	assert.NoError(err)
+	names, err := dir.Readdirnames(-1)
+	assert.NoError(err)
+	dir.Close()
+	sort.Strings(names)
+	assert.Equal([]string{"dir.scss", "main.css", "main.scss", "other.css", "other.scss", "plain.txt", "typo.css.no"}, names)
+
+	// Readdir in batches.
+	dir, err = rfs.Open("css")
+	assert.NoError(err)
+	defer dir.Close()
+	var batched []string
+	for {
+		fis, err := dir.Readdir(2)
+		if err != nil {
+			break
+		}
+		for _, fi := range fis {
+			batched = append(batched, fi.Name())
+		}
+	}
+	sort.Strings(batched)
+	assert.Equal(names, batched)
+
+	assert.Error(afero.WriteFile(rfs, filepath.FromSlash("css/new.css"), []byte("new"), 0755))
+}
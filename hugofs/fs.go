@@ -86,3 +86,13 @@ func getWorkingDirFs(base afero.Fs, cfg config.Provider) *afero.BasePathFs {
 func isWrite(flag int) bool {
 	return flag&os.O_RDWR != 0 || flag&os.O_WRONLY != 0
 }
+
+// IsBrokenSymlink reports whether fi, as returned from an Lstat of filename in
+// fs, is a symbolic link to a file that does not exist.
+func IsBrokenSymlink(fs afero.Fs, filename string, fi os.FileInfo) bool {
+	if fi == nil || fi.Mode()&os.ModeSymlink != os.ModeSymlink {
+		return false
+	}
+	_, err := fs.Stat(filename)
+	return os.IsNotExist(err)
+}
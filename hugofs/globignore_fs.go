@@ -0,0 +1,184 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs      = (*globIgnoreFs)(nil)
+	_ afero.Lstater = (*globIgnoreFs)(nil)
+)
+
+type globIgnoreFs struct {
+	afero.Fs
+	globs ignoreGlobs
+}
+
+// NewGlobIgnoreFs creates a new filesystem that hides any file or directory
+// matching one of the given glob patterns, much like a .gitignore file.
+// Hidden files are omitted from directory listings, and Stat and Open will
+// return an os.IsNotExist error. Anything below a hidden directory is also
+// hidden.
+//
+// Patterns use '/' as separator and support "**" to match across directories.
+// A pattern starting with '/' is anchored to the root of the filesystem,
+// other patterns may match at any level. A pattern that is not a valid glob
+// is matched literally.
+func NewGlobIgnoreFs(fs afero.Fs, patterns []string) afero.Fs {
+	return &globIgnoreFs{Fs: fs, globs: compileIgnoreGlobs(patterns)}
+}
+
+// ignoreGlobs is a compiled list of .gitignore like patterns.
+type ignoreGlobs []glob.Glob
+
+func compileIgnoreGlobs(patterns []string) ignoreGlobs {
+	var globs ignoreGlobs
+
+	for _, pattern := range patterns {
+		anchored := strings.HasPrefix(pattern, "/")
+		pattern = strings.Trim(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		globs = append(globs, compileIgnoreGlob(pattern))
+		if !anchored {
+			globs = append(globs, compileIgnoreGlob("**/"+pattern))
+		}
+	}
+
+	return globs
+}
+
+func compileIgnoreGlob(pattern string) glob.Glob {
+	g, err := glob.Compile(pattern, '/')
+	if err != nil {
+		return glob.MustCompile(glob.QuoteMeta(pattern), '/')
+	}
+	return g
+}
+
+// match reports whether the given filename or any of its parent
+// directories matches any of the patterns.
+func (globs ignoreGlobs) match(name string) bool {
+	if len(globs) == 0 {
+		return false
+	}
+
+	name = strings.Trim(filepath.ToSlash(filepath.Clean(name)), "/")
+	if name == "" || name == "." {
+		return false
+	}
+
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) && name[i] != '/' {
+			continue
+		}
+		p := name[:i]
+		for _, g := range globs {
+			if g.Match(p) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isIgnored reports whether the given filename or any of its parent
+// directories matches any of the ignore patterns.
+func (fs *globIgnoreFs) isIgnored(name string) bool {
+	return fs.globs.match(name)
+}
+
+func (fs *globIgnoreFs) notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (fs *globIgnoreFs) Stat(name string) (os.FileInfo, error) {
+	if fs.isIgnored(name) {
+		return nil, fs.notExist("stat", name)
+	}
+	return fs.Fs.Stat(name)
+}
+
+// LstatIfPossible returns the os.FileInfo structure describing a given file.
+// It attempts to use Lstat if supported or defers to the os.  In addition to
+// the FileInfo, a boolean is returned telling whether Lstat was called.
+func (fs *globIgnoreFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if fs.isIgnored(name) {
+		return nil, false, fs.notExist("lstat", name)
+	}
+
+	if ls, ok := fs.Fs.(afero.Lstater); ok {
+		return ls.LstatIfPossible(name)
+	}
+
+	fi, err := fs.Fs.Stat(name)
+	return fi, false, err
+}
+
+func (fs *globIgnoreFs) Open(name string) (afero.File, error) {
+	if fs.isIgnored(name) {
+		return nil, fs.notExist("open", name)
+	}
+
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &globIgnoreFile{File: f, fs: fs, dirname: name}, nil
+}
+
+func (fs *globIgnoreFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if fs.isIgnored(name) {
+		return nil, fs.notExist("open", name)
+	}
+
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &globIgnoreFile{File: f, fs: fs, dirname: name}, nil
+}
+
+func (fs *globIgnoreFs) Name() string {
+	return "globIgnoreFs"
+}
+
+type globIgnoreFile struct {
+	afero.File
+	fs      *globIgnoreFs
+	dirname string
+}
+
+// Readdir works as in os.File, but any ignored file is skipped.
+func (f *globIgnoreFile) Readdir(count int) ([]os.FileInfo, error) {
+	return filteredReaddir(f.File, count, func(fi os.FileInfo) bool {
+		return !f.fs.isIgnored(filepath.Join(f.dirname, fi.Name()))
+	})
+}
+
+// Readdirnames works as in os.File, but any ignored file is skipped.
+func (f *globIgnoreFile) Readdirnames(count int) ([]string, error) {
+	return readdirnames(f.Readdir(count))
+}
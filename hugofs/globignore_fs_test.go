@@ -0,0 +1,108 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobIgnoreFs(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{
+		"a.md",
+		"a.md~",
+		".DS_Store",
+		"blog/b.md",
+		"blog/.DS_Store",
+		"blog/drafts/c.md",
+		"drafts/d.md",
+		"docs/private/e.md",
+		"docs/guide/private/f.md",
+	} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("content"), 0755))
+	}
+
+	ifs := NewGlobIgnoreFs(fs, []string{"*~", ".DS_Store", "/drafts", "docs/**/private"})
+
+	for _, test := range []struct {
+		filename string
+		ignored  bool
+	}{
+		{"a.md", false},
+		{"a.md~", true},
+		{".DS_Store", true},
+		{"blog", false},
+		{"blog/b.md", false},
+		{"blog/.DS_Store", true},
+		// Anchored.
+		{"blog/drafts", false},
+		{"blog/drafts/c.md", false},
+		{"drafts", true},
+		{"drafts/d.md", true},
+		{"docs/private", true},
+		{"docs/private/e.md", true},
+		{"docs/guide/private/f.md", true},
+	} {
+		filename := filepath.FromSlash(test.filename)
+
+		_, err := ifs.Stat(filename)
+		assert.Equal(test.ignored, os.IsNotExist(err), test.filename)
+		_, _, err = ifs.(afero.Lstater).LstatIfPossible(filename)
+		assert.Equal(test.ignored, os.IsNotExist(err), test.filename)
+		f, err := ifs.Open(filename)
+		assert.Equal(test.ignored, os.IsNotExist(err), test.filename)
+		if err == nil {
+			f.Close()
+		}
+	}
+
+	readdirnames := func(dirname string) []string {
+		f, err := ifs.Open(filepath.FromSlash(dirname))
+		assert.NoError(err)
+		defer f.Close()
+		names, err := f.Readdirnames(-1)
+		assert.NoError(err)
+		sort.Strings(names)
+		return names
+	}
+
+	assert.Equal([]string{"a.md", "blog", "docs"}, readdirnames(""))
+	assert.Equal([]string{"b.md", "drafts"}, readdirnames("blog"))
+	assert.Equal([]string{"guide"}, readdirnames("docs"))
+	assert.Equal([]string{}, readdirnames("docs/guide"))
+
+	// Readdir in batches.
+	f, err := ifs.Open("")
+	assert.NoError(err)
+	defer f.Close()
+	var names []string
+	for {
+		fis, err := f.Readdir(1)
+		if err != nil {
+			break
+		}
+		assert.Len(fis, 1)
+		names = append(names, fis[0].Name())
+	}
+	sort.Strings(names)
+	assert.Equal([]string{"a.md", "blog", "docs"}, names)
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ fs.FS          = (*ioFS)(nil)
+	_ fs.StatFS      = (*ioFS)(nil)
+	_ fs.ReadDirFS   = (*ioFS)(nil)
+	_ fs.ReadDirFile = (*ioFile)(nil)
+	_ fs.DirEntry    = (*DirEntry)(nil)
+)
+
+// ToFS adapts the given filesystem to an io/fs.FS, so it can be used with
+// functions such as fs.WalkDir and fs.Glob.
+// The names are slash separated and relative to the root of afs, see
+// fs.ValidPath. The os.FileInfo values from afs, e.g. a LanguageFileInfo, are
+// passed through as is, and directory entries are of type *DirEntry.
+func ToFS(afs afero.Fs) fs.FS {
+	return &ioFS{afs: afs}
+}
+
+type ioFS struct {
+	afs afero.Fs
+}
+
+func (f *ioFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	file, err := f.afs.Open(filepath.FromSlash(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ioFile{File: file}, nil
+}
+
+func (f *ioFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return f.afs.Stat(filepath.FromSlash(name))
+}
+
+// ReadDir reads the named directory and returns its entries sorted by filename.
+func (f *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	file, err := f.afs.Open(filepath.FromSlash(name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fis, err := file.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+
+	return toDirEntries(fis), nil
+}
+
+type ioFile struct {
+	afero.File
+}
+
+func (f *ioFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	fis, err := f.File.Readdir(count)
+	return toDirEntries(fis), err
+}
+
+// DirEntry is the fs.DirEntry returned from the filesystems created with ToFS.
+type DirEntry struct {
+	fi os.FileInfo
+}
+
+func toDirEntries(fis []os.FileInfo) []fs.DirEntry {
+	entries := make([]fs.DirEntry, len(fis))
+	for i, fi := range fis {
+		entries[i] = &DirEntry{fi: fi}
+	}
+	return entries
+}
+
+// Name returns the name of the file or directory described by the entry.
+func (e *DirEntry) Name() string {
+	return e.fi.Name()
+}
+
+// IsDir reports whether the entry describes a directory.
+func (e *DirEntry) IsDir() bool {
+	return e.fi.IsDir()
+}
+
+// Type returns the type bits for the entry.
+func (e *DirEntry) Type() fs.FileMode {
+	return e.fi.Mode().Type()
+}
+
+// Info returns the os.FileInfo from the underlying filesystem, e.g.
+// a RealFilenameInfo.
+func (e *DirEntry) Info() (fs.FileInfo, error) {
+	return e.fi, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToFS(t *testing.T) {
+	assert := require.New(t)
+	mfs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(mfs, filepath.FromSlash("project/blog/a.txt"), []byte("content a"), 0755))
+	assert.NoError(afero.WriteFile(mfs, filepath.FromSlash("mytheme/b.txt"), []byte("content b"), 0755))
+
+	rfs, err := NewRootMappingFs(mfs, "p", "project", "t", "mytheme")
+	assert.NoError(err)
+
+	iofs := ToFS(rfs)
+
+	var paths, realFilenames []string
+	assert.NoError(fs.WalkDir(iofs, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		if !d.IsDir() {
+			fi, err := fs.Stat(iofs, path)
+			if err != nil {
+				return err
+			}
+			realFilenames = append(realFilenames, fi.(RealFilenameInfo).RealFilename())
+		}
+		return nil
+	}))
+
+	assert.Equal([]string{".", "p", "p/blog", "p/blog/a.txt", "t", "t/b.txt"}, paths)
+	assert.Equal([]string{filepath.FromSlash("project/blog/a.txt"), filepath.FromSlash("mytheme/b.txt")}, realFilenames)
+
+	b, err := fs.ReadFile(iofs, "t/b.txt")
+	assert.NoError(err)
+	assert.Equal("content b", string(b))
+
+	matches, err := fs.Glob(iofs, "*/*.txt")
+	assert.NoError(err)
+	assert.Equal([]string{"t/b.txt"}, matches)
+
+	_, err = fs.Stat(iofs, "p/nope.txt")
+	assert.True(errors.Is(err, fs.ErrNotExist))
+
+	_, err = iofs.Open("../p")
+	assert.True(errors.Is(err, fs.ErrInvalid))
+}
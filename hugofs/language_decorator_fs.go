@@ -0,0 +1,133 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs          = (*langDecoratorFs)(nil)
+	_ afero.Lstater     = (*langDecoratorFs)(nil)
+	_ LanguageAnnouncer = (*langFileInfo)(nil)
+)
+
+type langDecoratorFs struct {
+	afero.Fs
+	languages map[string]bool
+}
+
+// NewLangDecoratorFs creates a new filesystem where the os.FileInfo of every
+// file returned from Stat, LstatIfPossible and Readdir is a LanguageAnnouncer
+// with the language taken from the file name, e.g. "sv" for "post.sv.md".
+// Files without a valid language in the name get an empty language.
+// Directories are passed through as is.
+// Unlike NewLanguageFs, this has no language of its own and does not change
+// any file names, so it can be used on top of any filesystem.
+func NewLangDecoratorFs(fs afero.Fs, languages map[string]bool) afero.Fs {
+	return &langDecoratorFs{Fs: fs, languages: languages}
+}
+
+func (fs *langDecoratorFs) Stat(name string) (os.FileInfo, error) {
+	fi, err := fs.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.decorate(fi), nil
+}
+
+// LstatIfPossible returns the os.FileInfo structure describing a given file.
+// It attempts to use Lstat if supported or defers to the os.  In addition to
+// the FileInfo, a boolean is returned telling whether Lstat was called.
+func (fs *langDecoratorFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	var (
+		fi  os.FileInfo
+		b   bool
+		err error
+	)
+
+	if ls, ok := fs.Fs.(afero.Lstater); ok {
+		fi, b, err = ls.LstatIfPossible(name)
+	} else {
+		fi, err = fs.Fs.Stat(name)
+	}
+
+	if err != nil {
+		return nil, b, err
+	}
+
+	return fs.decorate(fi), b, nil
+}
+
+func (fs *langDecoratorFs) Open(name string) (afero.File, error) {
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &langDecoratorFile{File: f, fs: fs}, nil
+}
+
+func (fs *langDecoratorFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &langDecoratorFile{File: f, fs: fs}, nil
+}
+
+func (fs *langDecoratorFs) Name() string {
+	return "langDecoratorFs"
+}
+
+func (fs *langDecoratorFs) decorate(fi os.FileInfo) os.FileInfo {
+	if fi.IsDir() {
+		return fi
+	}
+	lang, translationBaseName := langInfoFrom(fs.languages, filepath.Base(fi.Name()))
+	return &langFileInfo{FileInfo: fi, lang: lang, translationBaseName: translationBaseName}
+}
+
+type langDecoratorFile struct {
+	afero.File
+	fs *langDecoratorFs
+}
+
+func (f *langDecoratorFile) Readdir(count int) ([]os.FileInfo, error) {
+	fis, err := f.File.Readdir(count)
+	for i, fi := range fis {
+		fis[i] = f.fs.decorate(fi)
+	}
+	return fis, err
+}
+
+type langFileInfo struct {
+	os.FileInfo
+	lang                string
+	translationBaseName string
+}
+
+// Lang returns the file's language (ie. "sv"), or an empty string if not set
+// in the file name.
+func (fi *langFileInfo) Lang() string {
+	return fi.lang
+}
+
+// TranslationBaseName returns the base filename without any extension or language
+// identifiers (ie. "page").
+func (fi *langFileInfo) TranslationBaseName() string {
+	return fi.translationBaseName
+}
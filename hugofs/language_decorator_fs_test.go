@@ -0,0 +1,87 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLangDecoratorFs(t *testing.T) {
+	assert := require.New(t)
+	mfs := afero.NewMemMapFs()
+
+	for _, filename := range []string{"post.sv.md", "post.md", "post.xx.md", "sub/a.en.md"} {
+		assert.NoError(afero.WriteFile(mfs, filepath.Join("blog", filepath.FromSlash(filename)), []byte("content"), 0755))
+	}
+
+	fs := NewLangDecoratorFs(mfs, map[string]bool{"sv": true, "en": true})
+
+	expected := map[string][2]string{
+		"post.sv.md": {"sv", "post"},
+		"post.md":    {"", "post"},
+		"post.xx.md": {"", "post.xx"},
+	}
+
+	for name, info := range expected {
+		fi, err := fs.Stat(filepath.Join("blog", name))
+		assert.NoError(err)
+		assert.Equal(name, fi.Name())
+		la := fi.(LanguageAnnouncer)
+		assert.Equal(info[0], la.Lang(), name)
+		assert.Equal(info[1], la.TranslationBaseName(), name)
+	}
+
+	fis, err := afero.ReadDir(fs, "blog")
+	assert.NoError(err)
+	assert.Len(fis, 4)
+	for _, fi := range fis {
+		if fi.IsDir() {
+			assert.Equal("sub", fi.Name())
+			_, ok := fi.(LanguageAnnouncer)
+			assert.False(ok)
+			continue
+		}
+		info := expected[fi.Name()]
+		assert.Equal(info[0], fi.(LanguageAnnouncer).Lang(), fi.Name())
+	}
+
+	fi, _, err := fs.(afero.Lstater).LstatIfPossible(filepath.FromSlash("blog/sub/a.en.md"))
+	assert.NoError(err)
+	assert.Equal("en", fi.(LanguageAnnouncer).Lang())
+}
+
+func TestLangInfoFrom(t *testing.T) {
+	assert := require.New(t)
+	languages := map[string]bool{"sv": true, "en": true}
+
+	for _, test := range []struct {
+		name                string
+		lang                string
+		translationBaseName string
+	}{
+		{"post.sv.md", "sv", "post"},
+		{"post.md", "", "post"},
+		{"post.xx.md", "", "post.xx"},
+		{"post", "", "post"},
+		{"my.post.en.html", "en", "my.post"},
+	} {
+		lang, translationBaseName := langInfoFrom(languages, test.name)
+		assert.Equal(test.lang, lang, test.name)
+		assert.Equal(test.translationBaseName, translationBaseName, test.name)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+var _ afero.Fs = (*languageFallbackFs)(nil)
+
+type languageFallbackFs struct {
+	afero.Fs
+
+	// The language rank, lower is better.
+	rank map[string]int
+}
+
+// NewLanguageFallbackFs creates a new filesystem on top of a language
+// filesystem, e.g. one created with NewLanguageCompositeFs, where each
+// directory listing holds at most one translation of a file: The one with its
+// language first in langs, e.g. "sv" and then "en" as a fallback for any file
+// not translated to Swedish. Files in any other language are left out.
+// Entries that are not a *LanguageFileInfo, e.g. directories, are passed
+// through as is. Stat and Open of a file left out of its directory listing
+// return an os.IsNotExist error.
+func NewLanguageFallbackFs(fs afero.Fs, langs []string) afero.Fs {
+	rank := make(map[string]int)
+	for i, lang := range langs {
+		if _, found := rank[lang]; !found {
+			rank[lang] = i
+		}
+	}
+	return &languageFallbackFs{Fs: fs, rank: rank}
+}
+
+func (fs *languageFallbackFs) Open(name string) (afero.File, error) {
+	fi, err := fs.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fs.isListed(name, fi) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &languageFallbackFile{File: f, fs: fs}, nil
+}
+
+func (fs *languageFallbackFs) Stat(name string) (os.FileInfo, error) {
+	fi, err := fs.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fs.isListed(name, fi) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return fi, nil
+}
+
+// isListed reports whether fi, the FileInfo of name, is kept in the filtered
+// listing of its directory.
+func (fs *languageFallbackFs) isListed(name string, fi os.FileInfo) bool {
+	lfi, ok := fi.(*LanguageFileInfo)
+	if !ok || lfi.IsDir() {
+		return true
+	}
+	if _, found := fs.rank[lfi.Lang()]; !found {
+		return false
+	}
+
+	fis, err := afero.ReadDir(fs.Fs, filepath.Dir(name))
+	if err != nil {
+		return false
+	}
+	for _, fi := range fs.filter(fis) {
+		if efi, ok := fi.(*LanguageFileInfo); ok && efi.RealName() == lfi.RealName() && efi.Lang() == lfi.Lang() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (fs *languageFallbackFs) Name() string {
+	return "languageFallbackFs"
+}
+
+// filter keeps the best ranked translation of each file.
+func (fs *languageFallbackFs) filter(fis []os.FileInfo) []os.FileInfo {
+	var (
+		result []os.FileInfo
+		index  = make(map[string]int)
+	)
+
+	for _, fi := range fis {
+		lfi, ok := fi.(*LanguageFileInfo)
+		if !ok || lfi.IsDir() {
+			result = append(result, fi)
+			continue
+		}
+
+		rank, found := fs.rank[lfi.Lang()]
+		if !found {
+			continue
+		}
+
+		key := lfi.TranslationBaseName() + filepath.Ext(lfi.RealName())
+
+		if i, found := index[key]; found {
+			if rank < fs.rank[result[i].(*LanguageFileInfo).Lang()] {
+				result[i] = lfi
+			}
+			continue
+		}
+
+		index[key] = len(result)
+		result = append(result, lfi)
+	}
+
+	return result
+}
+
+type languageFallbackFile struct {
+	afero.File
+	fs *languageFallbackFs
+
+	dir bufferedDir
+}
+
+// Readdir works as in os.File, but with at most one translation of each file.
+// Note that the translations of a file may be spread across the directory, so
+// the entire directory is read on the first call.
+func (f *languageFallbackFile) Readdir(count int) ([]os.FileInfo, error) {
+	return f.dir.readdir(f.File, count, f.fs.filter)
+}
+
+func (f *languageFallbackFile) Readdirnames(count int) ([]string, error) {
+	return readdirnames(f.Readdir(count))
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageFallbackFs(t *testing.T) {
+	assert := require.New(t)
+	languages := map[string]bool{"en": true, "sv": true, "nn": true}
+	m := afero.NewMemMapFs()
+
+	for _, filename := range []string{
+		"blog/post.md",
+		"blog/post.sv.md",
+		"blog/about.md",
+		"blog/contact.nn.md",
+		"blog/sub/page.md",
+	} {
+		assert.NoError(afero.WriteFile(m, filepath.Join("/my/base", filepath.FromSlash(filename)), []byte(filename), 0755))
+	}
+
+	lfs := NewLanguageFs("en", languages, afero.NewBasePathFs(m, filepath.FromSlash("/my/base")))
+
+	collect := func(langs ...string) map[string]string {
+		fs := NewLanguageFallbackFs(lfs, langs)
+		f, err := fs.Open("blog")
+		assert.NoError(err)
+		defer f.Close()
+		fis, err := f.Readdir(-1)
+		assert.NoError(err)
+
+		m := make(map[string]string)
+		for _, fi := range fis {
+			if fi.IsDir() {
+				m[fi.Name()] = "dir"
+				continue
+			}
+			lfi := fi.(*LanguageFileInfo)
+			m[lfi.RealName()] = lfi.Lang()
+		}
+		return m
+	}
+
+	assert.Equal(map[string]string{"post.sv.md": "sv", "about.md": "en", "sub": "dir"}, collect("sv", "en"))
+	assert.Equal(map[string]string{"post.md": "en", "about.md": "en", "sub": "dir"}, collect("en", "sv"))
+	assert.Equal(map[string]string{"post.sv.md": "sv", "sub": "dir"}, collect("sv"))
+	assert.Equal(map[string]string{"post.sv.md": "sv", "about.md": "en", "contact.nn.md": "nn", "sub": "dir"}, collect("sv", "nn", "en"))
+
+	// Stat and Open hide the same files.
+	fs := NewLanguageFallbackFs(lfs, []string{"sv", "en"})
+	for _, test := range []struct {
+		filename string
+		found    bool
+	}{
+		{"blog/post.sv.md", true},
+		{"blog/about.md", true},
+		{"blog/sub", true},
+		{"blog/sub/page.md", true},
+		// Shadowed by the Swedish translation.
+		{"blog/post.md", false},
+		// Not in any of the languages.
+		{"blog/contact.nn.md", false},
+	} {
+		filename := filepath.FromSlash(test.filename)
+		_, err := fs.Stat(filename)
+		assert.Equal(test.found, err == nil, test.filename)
+		assert.Equal(!test.found, os.IsNotExist(err), test.filename)
+		f, err := fs.Open(filename)
+		assert.Equal(test.found, err == nil, test.filename)
+		assert.Equal(!test.found, os.IsNotExist(err), test.filename)
+		if err == nil {
+			f.Close()
+		}
+	}
+
+	// Batched.
+	f, err := fs.Open("blog")
+	assert.NoError(err)
+	defer f.Close()
+	var names []string
+	for {
+		batch, err := f.Readdirnames(2)
+		if err != nil {
+			break
+		}
+		names = append(names, batch...)
+	}
+	sort.Strings(names)
+	assert.Equal([]string{"__hugofs_en_about.md", "__hugofs_en_post.sv.md", "sub"}, names)
+}
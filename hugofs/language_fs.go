@@ -228,6 +228,20 @@ func (fs *LanguageFs) Open(name string) (afero.File, error) {
 	return &languageFile{File: f, fs: fs}, nil
 }
 
+// OpenFile opens the named file using the given flags and mode.
+func (fs *LanguageFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	name, err := fs.realName(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+
+	if err != nil {
+		return nil, err
+	}
+	return &languageFile{File: f, fs: fs}, nil
+}
+
 // LstatIfPossible returns the os.FileInfo structure describing a given file.
 // It attempts to use Lstat if supported or defers to the os.  In addition to
 // the FileInfo, a boolean is returned telling whether Lstat was called.
@@ -298,20 +312,12 @@ func (fs *LanguageFs) newLanguageFileInfo(filename string, fi os.FileInfo) (*Lan
 		// Try to extract the language from the file name.
 		// Any valid language identificator in the name will win over the
 		// language set on the file system, e.g. "mypost.en.md".
-		baseName := filepath.Base(name)
-		ext := filepath.Ext(baseName)
-		baseNameNoExt = baseName
-
-		if ext != "" {
-			baseNameNoExt = strings.TrimSuffix(baseNameNoExt, ext)
-		}
+		ext := filepath.Ext(name)
 
-		fileLangExt := filepath.Ext(baseNameNoExt)
-		fileLang := strings.TrimPrefix(fileLangExt, ".")
-
-		if fs.languages[fileLang] {
+		var fileLang string
+		fileLang, baseNameNoExt = langInfoFrom(fs.languages, name)
+		if fileLang != "" {
 			lang = fileLang
-			baseNameNoExt = strings.TrimSuffix(baseNameNoExt, fileLangExt)
 		}
 
 		// This connects the filename to the filesystem, not the language.
@@ -344,3 +350,19 @@ func (fs *LanguageFs) newLanguageFileInfo(filename string, fi os.FileInfo) (*Lan
 		baseDir:             fs.basePath,
 		FileInfo:            fi}, nil
 }
+
+// langInfoFrom extracts the language and the translation base name from the
+// given file name, e.g. "sv" and "page" from "page.sv.md". If the name has no
+// valid language identificator, the language is empty.
+func langInfoFrom(languages map[string]bool, name string) (lang, translationBaseName string) {
+	baseNameNoExt := strings.TrimSuffix(name, filepath.Ext(name))
+
+	fileLangExt := filepath.Ext(baseNameNoExt)
+	fileLang := strings.TrimPrefix(fileLangExt, ".")
+
+	if languages[fileLang] {
+		return fileLang, strings.TrimSuffix(baseNameNoExt, fileLangExt)
+	}
+
+	return "", baseNameNoExt
+}
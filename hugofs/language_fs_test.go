@@ -14,6 +14,7 @@
 package hugofs
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -98,3 +99,36 @@ func TestFilenamesHandling(t *testing.T) {
 	}
 
 }
+
+func TestLanguageFsOpenFile(t *testing.T) {
+	languages := map[string]bool{
+		"sv": true,
+		"en": true,
+	}
+	base := filepath.FromSlash("/my/base")
+	assert := require.New(t)
+	m := afero.NewMemMapFs()
+	lfs := NewLanguageFs("sv", languages, afero.NewBasePathFs(m, base))
+
+	assert.NoError(afero.WriteFile(lfs, filepath.FromSlash("sect/page.md"), []byte("abc"), 0777))
+	assert.NoError(afero.WriteFile(lfs, filepath.FromSlash("sect/page.en.md"), []byte("abc"), 0777))
+
+	f, err := lfs.OpenFile("sect", os.O_RDONLY, 0)
+	assert.NoError(err)
+	defer f.Close()
+
+	fis, err := f.Readdir(-1)
+	assert.NoError(err)
+	assert.Len(fis, 2)
+
+	for _, fi := range fis {
+		lfi, ok := fi.(*LanguageFileInfo)
+		assert.True(ok)
+		assert.Equal(filepath.Join(base, "sect", lfi.RealName()), lfi.Filename())
+	}
+
+	// The language marked names are also supported.
+	f, err = lfs.OpenFile(filepath.FromSlash("sect/__hugofs_sv_page.md"), os.O_RDONLY, 0)
+	assert.NoError(err)
+	f.Close()
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs         = (*languageSuffixFs)(nil)
+	_ afero.Lstater    = (*languageSuffixFs)(nil)
+	_ RealFilenameInfo = (*languageSuffixFileInfo)(nil)
+)
+
+type languageSuffixFs struct {
+	afero.Fs
+	lang      string
+	languages map[string]bool
+}
+
+// NewLanguageSuffixFs creates a new filesystem with a language view of fs.
+// A file with the given language in its name, e.g. "menu.sv.yaml", is presented
+// without it ("menu.yaml"), and wins over any file already named so. Files
+// marked with any other of the given languages are hidden. Any other file is
+// passed through as is.
+func NewLanguageSuffixFs(lang string, languages map[string]bool, fs afero.Fs) afero.Fs {
+	return &languageSuffixFs{Fs: fs, lang: lang, languages: languages}
+}
+
+// langName returns the name of the file in this language, e.g. "menu.sv.yaml"
+// for "menu.yaml".
+func (fs *languageSuffixFs) langName(name string) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + fs.lang + ext
+}
+
+// stat resolves the given name to a file in the underlying filesystem.
+func (fs *languageSuffixFs) stat(name string, lstat bool) (string, os.FileInfo, bool, error) {
+	statFn := func(name string) (os.FileInfo, bool, error) {
+		if lstat {
+			if ls, ok := fs.Fs.(afero.Lstater); ok {
+				return ls.LstatIfPossible(name)
+			}
+		}
+		fi, err := fs.Fs.Stat(name)
+		return fi, false, err
+	}
+
+	dir, base := filepath.Split(name)
+
+	if lang, _ := langInfoFrom(fs.languages, base); lang != "" {
+		return "", nil, false, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	if base != "" {
+		langName := filepath.Join(dir, fs.langName(base))
+		if fi, b, err := statFn(langName); err == nil && !fi.IsDir() {
+			return langName, fi, b, nil
+		}
+	}
+
+	fi, b, err := statFn(name)
+
+	return name, fi, b, err
+}
+
+func (fs *languageSuffixFs) Stat(name string) (os.FileInfo, error) {
+	realName, fi, _, err := fs.stat(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return fs.newFileInfo(fi, filepath.Base(name), realName), nil
+}
+
+// LstatIfPossible returns the os.FileInfo structure describing a given file.
+// It attempts to use Lstat if supported or defers to the os.  In addition to
+// the FileInfo, a boolean is returned telling whether Lstat was called.
+func (fs *languageSuffixFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	realName, fi, b, err := fs.stat(name, true)
+	if err != nil {
+		return nil, b, err
+	}
+	return fs.newFileInfo(fi, filepath.Base(name), realName), b, nil
+}
+
+func (fs *languageSuffixFs) Open(name string) (afero.File, error) {
+	realName, _, _, err := fs.stat(name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.Fs.Open(realName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &languageSuffixFile{File: f, fs: fs, dirname: name}, nil
+}
+
+func (fs *languageSuffixFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if isWrite(flag) {
+		return fs.Fs.OpenFile(name, flag, perm)
+	}
+
+	realName, _, _, err := fs.stat(name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.Fs.OpenFile(realName, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &languageSuffixFile{File: f, fs: fs, dirname: name}, nil
+}
+
+func (fs *languageSuffixFs) Name() string {
+	return "languageSuffixFs"
+}
+
+func (fs *languageSuffixFs) newFileInfo(fi os.FileInfo, name, realName string) os.FileInfo {
+	if fi.IsDir() {
+		return fi
+	}
+
+	if rfi, ok := fi.(RealFilenameInfo); ok {
+		realName = rfi.RealFilename()
+	}
+
+	return &languageSuffixFileInfo{FileInfo: fi, name: name, realFilename: realName}
+}
+
+type languageSuffixFileInfo struct {
+	os.FileInfo
+	name         string
+	realFilename string
+}
+
+func (fi *languageSuffixFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *languageSuffixFileInfo) RealFilename() string {
+	return fi.realFilename
+}
+
+type languageSuffixFile struct {
+	afero.File
+	fs      *languageSuffixFs
+	dirname string
+
+	dir bufferedDir
+}
+
+// Readdir works as in os.File, but with the language resolved as described in
+// NewLanguageSuffixFs.
+func (f *languageSuffixFile) Readdir(count int) ([]os.FileInfo, error) {
+	return f.dir.readdir(f.File, count, f.filter)
+}
+
+func (f *languageSuffixFile) filter(fis []os.FileInfo) []os.FileInfo {
+	var (
+		result []os.FileInfo
+		index  = make(map[string]int)
+	)
+
+	for _, fi := range fis {
+		if fi.IsDir() {
+			result = append(result, fi)
+			continue
+		}
+
+		name := fi.Name()
+		lang, translationBaseName := langInfoFrom(f.fs.languages, name)
+		if lang != "" && lang != f.fs.lang {
+			continue
+		}
+		if lang != "" {
+			// E.g. "menu.yaml" for "menu.sv.yaml".
+			name = translationBaseName + filepath.Ext(name)
+		}
+
+		realName := filepath.Join(f.dirname, fi.Name())
+		lfi := f.fs.newFileInfo(fi, name, realName)
+
+		if i, found := index[name]; found {
+			if lang != "" {
+				result[i] = lfi
+			}
+			continue
+		}
+
+		index[name] = len(result)
+		result = append(result, lfi)
+	}
+
+	return result
+}
+
+// Readdirnames works as in os.File, but with the language resolved as
+// described in NewLanguageSuffixFs.
+func (f *languageSuffixFile) Readdirnames(count int) ([]string, error) {
+	return readdirnames(f.Readdir(count))
+}
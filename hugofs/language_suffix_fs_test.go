@@ -0,0 +1,87 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageSuffixFs(t *testing.T) {
+	assert := require.New(t)
+	mfs := afero.NewMemMapFs()
+	languages := map[string]bool{"en": true, "sv": true, "nn": true}
+
+	for _, filename := range []string{
+		"data/menu.yaml",
+		"data/menu.sv.yaml",
+		"data/authors.yaml",
+		"data/authors.nn.yaml",
+		"data/sub/links.sv.toml",
+		"data/sub.sv/a.toml",
+	} {
+		assert.NoError(afero.WriteFile(mfs, filepath.FromSlash(filename), []byte(filename), 0755))
+	}
+
+	fs := NewLanguageSuffixFs("sv", languages, mfs)
+
+	for _, test := range []struct {
+		filename string
+		expected string
+	}{
+		{"data/menu.yaml", "data/menu.sv.yaml"},
+		{"data/authors.yaml", "data/authors.yaml"},
+		{"data/sub/links.toml", "data/sub/links.sv.toml"},
+		{"data/menu.sv.yaml", ""},
+		{"data/authors.nn.yaml", ""},
+	} {
+		filename := filepath.FromSlash(test.filename)
+		fi, err := fs.Stat(filename)
+		if test.expected == "" {
+			assert.True(os.IsNotExist(err), test.filename)
+			continue
+		}
+		assert.NoError(err)
+		assert.Equal(filepath.Base(filename), fi.Name())
+		assert.Equal(filepath.FromSlash(test.expected), fi.(RealFilenameInfo).RealFilename())
+		b, err := afero.ReadFile(fs, filename)
+		assert.NoError(err)
+		assert.Equal(test.expected, string(b))
+	}
+
+	readdirnames := func(fs afero.Fs, dirname string) []string {
+		f, err := fs.Open(filepath.FromSlash(dirname))
+		assert.NoError(err)
+		defer f.Close()
+		names, err := f.Readdirnames(-1)
+		assert.NoError(err)
+		sort.Strings(names)
+		return names
+	}
+
+	assert.Equal([]string{"authors.yaml", "menu.yaml", "sub", "sub.sv"}, readdirnames(fs, "data"))
+	assert.Equal([]string{"links.toml"}, readdirnames(fs, "data/sub"))
+
+	fs = NewLanguageSuffixFs("en", languages, mfs)
+	assert.Equal([]string{"authors.yaml", "menu.yaml", "sub", "sub.sv"}, readdirnames(fs, "data"))
+	assert.Equal([]string{}, readdirnames(fs, "data/sub"))
+	b, err := afero.ReadFile(fs, filepath.FromSlash("data/menu.yaml"))
+	assert.NoError(err)
+	assert.Equal("data/menu.yaml", string(b))
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// Materialize returns a filename on the OS filesystem with the content of the
+// named file in fs, e.g. for use with external tools.
+// If the file's FileInfo is a RealFilenameInfo and that absolute filename exists
+// on disk with the same size and modification time, it is returned as is. Any
+// other file is copied to a temporary file, keeping its extension. The returned
+// cleanup func must be called when done; it will remove any temporary file.
+func Materialize(fs afero.Fs, name string) (filename string, cleanup func(), err error) {
+	fi, err := fs.Stat(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if rfi, ok := fi.(RealFilenameInfo); ok {
+		if filename := rfi.RealFilename(); isOnDisk(filename, fi) {
+			return filename, func() {}, nil
+		}
+	}
+
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	tmp, err := ioutil.TempFile("", "hugo_materialized_*"+filepath.Ext(name))
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup = func() {
+		os.Remove(tmp.Name())
+	}
+
+	_, err = io.Copy(tmp, f)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return tmp.Name(), cleanup, nil
+}
+
+func isOnDisk(filename string, fi os.FileInfo) bool {
+	if !filepath.IsAbs(filename) {
+		return false
+	}
+
+	ofi, err := os.Stat(filename)
+	if err != nil || ofi.IsDir() {
+		return false
+	}
+
+	return ofi.Size() == fi.Size() && ofi.ModTime().Equal(fi.ModTime())
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gohugoio/hugo/htesting/tempdir"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaterialize(t *testing.T) {
+	assert := require.New(t)
+
+	// In memory only.
+	mfs := afero.NewMemMapFs()
+	assert.NoError(afero.WriteFile(mfs, filepath.FromSlash("/my/images/sunset.jpg"), []byte("some content"), 0755))
+
+	filename, cleanup, err := Materialize(mfs, filepath.FromSlash("/my/images/sunset.jpg"))
+	assert.NoError(err)
+	assert.NotEqual(filepath.FromSlash("/my/images/sunset.jpg"), filename)
+	assert.Equal(".jpg", filepath.Ext(filename))
+	b, err := ioutil.ReadFile(filename)
+	assert.NoError(err)
+	assert.Equal("some content", string(b))
+	cleanup()
+	_, err = os.Stat(filename)
+	assert.True(os.IsNotExist(err))
+
+	_, _, err = Materialize(mfs, "nope.jpg")
+	assert.True(os.IsNotExist(err))
+
+	// On disk.
+	osfs := Os
+	d, clean, err := tempdir.Create(osfs, "hugofs-materialize")
+	assert.NoError(err)
+	defer clean()
+	assert.NoError(osfs.MkdirAll(filepath.Join(d, "images"), 0755))
+	assert.NoError(afero.WriteFile(osfs, filepath.Join(d, "images", "sunset.jpg"), []byte("some content"), 0755))
+
+	bfs := NewBasePathRealFilenameFs(afero.NewBasePathFs(osfs, d).(*afero.BasePathFs))
+	filename, cleanup, err = Materialize(bfs, filepath.Join("images", "sunset.jpg"))
+	assert.NoError(err)
+	assert.Equal(filepath.Join(d, "images", "sunset.jpg"), filename)
+	cleanup()
+	_, err = os.Stat(filename)
+	assert.NoError(err)
+
+	// Without a RealFilenameInfo the name is never looked up on disk.
+	filename, cleanup, err = Materialize(afero.NewBasePathFs(osfs, d), filepath.Join("images", "sunset.jpg"))
+	assert.NoError(err)
+	assert.NotEqual(filepath.Join(d, "images", "sunset.jpg"), filename)
+	b, err = ioutil.ReadFile(filename)
+	assert.NoError(err)
+	assert.Equal("some content", string(b))
+	cleanup()
+	_, err = os.Stat(filename)
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// filteredReaddir works as Readdir in os.File, but only entries for which keep
+// returns true are returned. With count > 0, f is read until count entries are
+// kept or the end of the directory is reached.
+func filteredReaddir(f afero.File, count int, keep func(fi os.FileInfo) bool) ([]os.FileInfo, error) {
+	var result []os.FileInfo
+
+	for {
+		n := count
+		if n > 0 {
+			n -= len(result)
+		}
+
+		fis, err := f.Readdir(n)
+		for _, fi := range fis {
+			if keep(fi) {
+				result = append(result, fi)
+			}
+		}
+
+		if err == io.EOF && len(result) > 0 {
+			err = nil
+		}
+
+		if err != nil || count <= 0 || len(result) >= count || len(fis) == 0 {
+			return result, err
+		}
+	}
+}
+
+// bufferedDir is used for directories where the entries returned from Readdir
+// depend on the entire directory, e.g. to pick one of the translations of a
+// file, that may be spread across the directory.
+type bufferedDir struct {
+	// The filtered directory entries not yet returned from Readdir.
+	pending []os.FileInfo
+	read    bool
+}
+
+// readdir works as Readdir in os.File. The entire directory is read from f
+// on the first call and passed through filter.
+func (d *bufferedDir) readdir(f afero.File, count int, filter func(fis []os.FileInfo) []os.FileInfo) ([]os.FileInfo, error) {
+	if !d.read {
+		fis, err := f.Readdir(-1)
+		if err != nil {
+			return nil, err
+		}
+		d.read = true
+		d.pending = filter(fis)
+	}
+
+	if count <= 0 {
+		fis := d.pending
+		d.pending = nil
+		return fis, nil
+	}
+
+	if len(d.pending) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(d.pending) {
+		count = len(d.pending)
+	}
+
+	fis := d.pending[:count]
+	d.pending = d.pending[count:]
+
+	return fis, nil
+}
+
+// readdirnames returns the names of the entries returned from a Readdir call,
+// for Readdirnames implementations built on top of Readdir.
+func readdirnames(fis []os.FileInfo, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(fis))
+	for i, fi := range fis {
+		names[i] = fi.Name()
+	}
+
+	return names, nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs = (*RecordingFs)(nil)
+	_ Reseter  = (*RecordingFs)(nil)
+)
+
+// RecordedFile is a file written to a RecordingFs.
+type RecordedFile struct {
+	// The filename as given to Create or OpenFile, cleaned.
+	Name string
+
+	// The number of bytes written. Overwriting a range with WriteAt
+	// does not add to it.
+	Size int64
+}
+
+// RecordingFs is a filesystem that records the files created or opened for
+// writing, e.g. to preview what a build would publish.
+type RecordingFs struct {
+	afero.Fs
+
+	// If set, nothing is written to the underlying filesystem. Any file
+	// created or opened for writing discards its content, and operations
+	// such as Mkdir and Remove do nothing.
+	// This must be set before the filesystem is used.
+	Discard bool
+
+	mu       sync.Mutex
+	recorded map[string]*RecordedFile
+}
+
+// NewRecordingFs creates a new RecordingFs on top of base.
+func NewRecordingFs(base afero.Fs) *RecordingFs {
+	return &RecordingFs{Fs: base, recorded: make(map[string]*RecordedFile)}
+}
+
+// Recorded returns the files written so far, sorted by name.
+func (fs *RecordingFs) Recorded() []RecordedFile {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	files := make([]RecordedFile, 0, len(fs.recorded))
+	for _, f := range fs.recorded {
+		files = append(files, *f)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	return files
+}
+
+// Reset clears the recorded files.
+func (fs *RecordingFs) Reset() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.recorded = make(map[string]*RecordedFile)
+}
+
+func (fs *RecordingFs) record(name string, truncate bool) *RecordedFile {
+	name = filepath.Clean(name)
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	r, found := fs.recorded[name]
+	if !found {
+		r = &RecordedFile{Name: name}
+		fs.recorded[name] = r
+	} else if truncate {
+		r.Size = 0
+	}
+
+	return r
+}
+
+func (fs *RecordingFs) Create(name string) (afero.File, error) {
+	if fs.Discard {
+		return fs.wrapFile(&discardFile{name: name, mode: 0666}, name, true), nil
+	}
+	f, err := fs.Fs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrapFile(f, name, true), nil
+}
+
+func (fs *RecordingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if !isWrite(flag) {
+		return fs.Fs.OpenFile(name, flag, perm)
+	}
+	truncate := flag&os.O_TRUNC != 0
+	if fs.Discard {
+		return fs.wrapFile(&discardFile{name: name, mode: perm}, name, truncate), nil
+	}
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrapFile(f, name, truncate), nil
+}
+
+func (fs *RecordingFs) wrapFile(f afero.File, name string, truncate bool) afero.File {
+	return &recordingFile{File: f, fs: fs, r: fs.record(name, truncate)}
+}
+
+func (fs *RecordingFs) Mkdir(name string, perm os.FileMode) error {
+	if fs.Discard {
+		return nil
+	}
+	return fs.Fs.Mkdir(name, perm)
+}
+
+func (fs *RecordingFs) MkdirAll(path string, perm os.FileMode) error {
+	if fs.Discard {
+		return nil
+	}
+	return fs.Fs.MkdirAll(path, perm)
+}
+
+func (fs *RecordingFs) Remove(name string) error {
+	if fs.Discard {
+		return nil
+	}
+	return fs.Fs.Remove(name)
+}
+
+func (fs *RecordingFs) RemoveAll(path string) error {
+	if fs.Discard {
+		return nil
+	}
+	return fs.Fs.RemoveAll(path)
+}
+
+func (fs *RecordingFs) Rename(oldname, newname string) error {
+	if fs.Discard {
+		return nil
+	}
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func (fs *RecordingFs) Chmod(name string, mode os.FileMode) error {
+	if fs.Discard {
+		return nil
+	}
+	return fs.Fs.Chmod(name, mode)
+}
+
+func (fs *RecordingFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if fs.Discard {
+		return nil
+	}
+	return fs.Fs.Chtimes(name, atime, mtime)
+}
+
+func (fs *RecordingFs) Name() string {
+	return "RecordingFs"
+}
+
+type recordingFile struct {
+	afero.File
+	fs *RecordingFs
+	r  *RecordedFile
+}
+
+func (f *recordingFile) add(n int) {
+	f.fs.mu.Lock()
+	f.r.Size += int64(n)
+	f.fs.mu.Unlock()
+}
+
+func (f *recordingFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.add(n)
+	return n, err
+}
+
+func (f *recordingFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(p, off)
+	f.fs.mu.Lock()
+	if end := off + int64(n); end > f.r.Size {
+		f.r.Size = end
+	}
+	f.fs.mu.Unlock()
+	return n, err
+}
+
+func (f *recordingFile) WriteString(s string) (int, error) {
+	n, err := f.File.WriteString(s)
+	f.add(n)
+	return n, err
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingFs(t *testing.T) {
+	assert := require.New(t)
+
+	for _, discard := range []bool{false, true} {
+		base := afero.NewMemMapFs()
+		assert.NoError(afero.WriteFile(base, "existing.txt", []byte("existing"), 0755))
+
+		fs := NewRecordingFs(base)
+		fs.Discard = discard
+
+		assert.NoError(fs.MkdirAll("blog", 0755))
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash("blog/index.html"), []byte("<h1>Blog</h1>"), 0755))
+		assert.NoError(afero.WriteFile(fs, "index.html", []byte("<h1>Home</h1>"), 0755))
+		// Written twice.
+		assert.NoError(afero.WriteFile(fs, "index.html", []byte("<h1>Home!</h1>"), 0755))
+
+		f, err := fs.OpenFile("log.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0755)
+		assert.NoError(err)
+		f.WriteString("a")
+		f.Close()
+		f, err = fs.OpenFile("log.txt", os.O_WRONLY|os.O_APPEND, 0755)
+		assert.NoError(err)
+		f.WriteString("b")
+		f.Close()
+
+		f, err = fs.Create("at.txt")
+		assert.NoError(err)
+		f.WriteAt([]byte("abcd"), 0)
+		f.WriteAt([]byte("xy"), 1)
+		f.WriteAt([]byte("z"), 3)
+		f.Close()
+
+		// Reading is not recorded.
+		b, err := afero.ReadFile(fs, "existing.txt")
+		assert.NoError(err)
+		assert.Equal("existing", string(b))
+
+		assert.Equal([]RecordedFile{
+			{Name: "at.txt", Size: 4},
+			{Name: filepath.FromSlash("blog/index.html"), Size: 13},
+			{Name: "index.html", Size: 14},
+			{Name: "log.txt", Size: 2},
+		}, fs.Recorded(), "discard=%t", discard)
+
+		for _, filename := range []string{"blog", filepath.FromSlash("blog/index.html"), "index.html", "log.txt"} {
+			_, err := base.Stat(filename)
+			assert.Equal(discard, os.IsNotExist(err), filename)
+		}
+
+		if !discard {
+			b, err := afero.ReadFile(base, "log.txt")
+			assert.NoError(err)
+			assert.Equal("ab", string(b))
+		}
+
+		assert.NoError(fs.Remove("existing.txt"))
+		_, err = base.Stat("existing.txt")
+		assert.Equal(!discard, os.IsNotExist(err))
+
+		fs.Reset()
+		assert.Len(fs.Recorded(), 0)
+	}
+}
@@ -14,13 +14,20 @@
 package hugofs
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/gobwas/glob"
 	radix "github.com/hashicorp/go-immutable-radix"
 	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
 )
 
 var filepathSeparator = string(filepath.Separator)
@@ -28,16 +35,44 @@ var filepathSeparator = string(filepath.Separator)
 // A RootMappingFs maps several roots into one. Note that the root of this filesystem
 // is directories only, and they will be returned in Readdir and Readdirnames
 // in the order given.
+// This is also the precedence order used when comparing the same path across
+// the virtual roots, e.g. in AllFilenames and ModuleShadowsProject. Looking up
+// a virtual path does not depend on the order, as every virtual root maps to
+// exactly one real directory.
 type RootMappingFs struct {
 	afero.Fs
 	rootMapToReal *radix.Node
 	virtualRoots  []string
+
+	// The same mappings as in rootMapToReal, keyed by the lower case virtual
+	// root. Used when FoldCase is set.
+	rootMapToRealFold *radix.Node
+
+	// If set, the virtual roots are matched case-insensitively, so e.g.
+	// "Layouts/_default" will resolve in a mapping of "layouts". The rest of
+	// the path is passed on as is to the underlying filesystem.
+	// This is typically used for the case-insensitive filesystems on macOS
+	// and Windows. If two virtual roots differ only in case, the first wins.
+	FoldCase bool
+
+	// If set, any symbolic link returned from Readdir is replaced with the
+	// os.FileInfo of its target, e.g. to get the target's IsDir and ModTime.
+	// Broken links are returned as is.
+	ResolveSymlinks bool
+
+	// If set, a file is hidden if a file with the same path relative to its
+	// virtual root exists in a virtual root earlier in the order, so e.g. the
+	// project's i18n/en.toml hides the theme's. Directories are never hidden.
+	HideShadowed bool
 }
 
 type rootMappingFile struct {
 	afero.File
 	fs   *RootMappingFs
 	name string
+
+	// The number of virtual roots read from the root so far.
+	offset int
 }
 
 type rootMappingFileInfo struct {
@@ -49,7 +84,7 @@ func (fi *rootMappingFileInfo) Name() string {
 }
 
 func (fi *rootMappingFileInfo) Size() int64 {
-	panic("not implemented")
+	return 0
 }
 
 func (fi *rootMappingFileInfo) Mode() os.FileMode {
@@ -57,7 +92,7 @@ func (fi *rootMappingFileInfo) Mode() os.FileMode {
 }
 
 func (fi *rootMappingFileInfo) ModTime() time.Time {
-	panic("not implemented")
+	return time.Time{}
 }
 
 func (fi *rootMappingFileInfo) IsDir() bool {
@@ -72,26 +107,102 @@ func newRootMappingDirFileInfo(name string) *rootMappingFileInfo {
 	return &rootMappingFileInfo{name: name}
 }
 
+// ErrAmbiguousRoot describes more than one real directory mapped to the same
+// virtual root. Use errors.Is to test for it, and errors.As with an
+// *AmbiguousRootError to get the conflicting mappings.
+var ErrAmbiguousRoot = errors.New("ambiguous root mapping")
+
+// AmbiguousRootError describes more than one real directory mapped to the
+// same virtual root, see NewRootMappingFs.
+type AmbiguousRootError struct {
+	// The conflicting mappings, all with the same From.
+	Mappings []RootMapping
+}
+
+func (e *AmbiguousRootError) Error() string {
+	var tos []string
+	for _, m := range e.Mappings {
+		tos = append(tos, strconv.Quote(m.To))
+	}
+	return fmt.Sprintf("%s: %q maps to %s", ErrAmbiguousRoot, e.Mappings[0].From, strings.Join(tos, " and "))
+}
+
+// Is reports whether target is ErrAmbiguousRoot.
+func (e *AmbiguousRootError) Is(target error) bool {
+	return target == ErrAmbiguousRoot
+}
+
 // NewRootMappingFs creates a new RootMappingFs on top of the provided with
 // a list of from, to string pairs of root mappings.
 // Note that 'from' represents a virtual root that maps to the actual filename in 'to'.
+// If the same virtual root is mapped more than once, the last mapping wins and
+// the *AmbiguousRootError is logged as a warning.
 func NewRootMappingFs(fs afero.Fs, fromTo ...string) (*RootMappingFs, error) {
 	rootMapToReal := radix.New().Txn()
+	rootMapToRealFold := radix.New().Txn()
 	var virtualRoots []string
 
 	for i := 0; i < len(fromTo); i += 2 {
 		vr := filepath.Clean(fromTo[i])
 		rr := filepath.Clean(fromTo[i+1])
 
-		// We need to preserve the original order for Readdir
-		virtualRoots = append(virtualRoots, vr)
+		lower := []byte(strings.ToLower(vr))
+
+		if existing, found := rootMapToReal.Get([]byte(vr)); found {
+			jww.WARN.Println(&AmbiguousRootError{Mappings: []RootMapping{{From: vr, To: existing.(string)}, {From: vr, To: rr}}})
+			if fold, _ := rootMapToRealFold.Get(lower); fold == existing {
+				rootMapToRealFold.Insert(lower, rr)
+			}
+		} else {
+			// We need to preserve the original order for Readdir
+			virtualRoots = append(virtualRoots, vr)
+		}
 
 		rootMapToReal.Insert([]byte(vr), rr)
+
+		if _, found := rootMapToRealFold.Get(lower); !found {
+			rootMapToRealFold.Insert(lower, rr)
+		}
 	}
 
 	return &RootMappingFs{Fs: fs,
-		virtualRoots:  virtualRoots,
-		rootMapToReal: rootMapToReal.Commit().Root()}, nil
+		virtualRoots:      virtualRoots,
+		rootMapToReal:     rootMapToReal.Commit().Root(),
+		rootMapToRealFold: rootMapToRealFold.Commit().Root()}, nil
+}
+
+// RootMapping describes a virtual root in a RootMappingFs.
+type RootMapping struct {
+	From string // The virtual root.
+	To   string // The real directory.
+}
+
+// Mounts returns the root mappings of this filesystem in the order given to
+// NewRootMappingFs.
+func (fs *RootMappingFs) Mounts() []RootMapping {
+	mounts := make([]RootMapping, len(fs.virtualRoots))
+	for i, vr := range fs.virtualRoots {
+		to, _ := fs.rootMapToReal.Get([]byte(vr))
+		mounts[i] = RootMapping{From: vr, To: to.(string)}
+	}
+	return mounts
+}
+
+// NewRootMappingFsEvalSymlinks works as NewRootMappingFs, but any symbolic link
+// in the real directories ('to') is resolved once when created, so the RootMappingFs
+// maps to the canonical paths. This only makes sense for the OS filesystem;
+// any 'to' that cannot be resolved is used as is.
+func NewRootMappingFsEvalSymlinks(fs afero.Fs, fromTo ...string) (*RootMappingFs, error) {
+	resolved := make([]string, len(fromTo))
+	copy(resolved, fromTo)
+
+	for i := 1; i < len(resolved); i += 2 {
+		if to, err := filepath.EvalSymlinks(resolved[i]); err == nil {
+			resolved[i] = to
+		}
+	}
+
+	return NewRootMappingFs(fs, resolved...)
 }
 
 // Stat returns the os.FileInfo structure describing a given file.  If there is
@@ -103,6 +214,9 @@ func (fs *RootMappingFs) Stat(name string) (os.FileInfo, error) {
 	realName := fs.realName(name)
 
 	fi, err := fs.Fs.Stat(realName)
+	if err == nil && fs.isShadowed(name, fi) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
 	if rfi, ok := fi.(RealFilenameInfo); ok {
 		return rfi, err
 	}
@@ -112,7 +226,11 @@ func (fs *RootMappingFs) Stat(name string) (os.FileInfo, error) {
 }
 
 func (fs *RootMappingFs) isRoot(name string) bool {
-	return name == "" || name == filepathSeparator
+	if name == "" {
+		return true
+	}
+	name = filepath.Clean(filepath.FromSlash(name))
+	return name == "." || name == filepathSeparator
 
 }
 
@@ -122,6 +240,9 @@ func (fs *RootMappingFs) Open(name string) (afero.File, error) {
 		return &rootMappingFile{name: name, fs: fs}, nil
 	}
 	realName := fs.realName(name)
+	if err := fs.checkShadowed("open", name, realName); err != nil {
+		return nil, err
+	}
 	f, err := fs.Fs.Open(realName)
 	if err != nil {
 		return nil, err
@@ -129,6 +250,26 @@ func (fs *RootMappingFs) Open(name string) (afero.File, error) {
 	return &rootMappingFile{File: f, name: name, fs: fs}, nil
 }
 
+// OpenFile opens the named file using the given flags and mode.
+// Note that the root can only be opened for reading.
+func (fs *RootMappingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if fs.isRoot(name) {
+		if isWrite(flag) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EPERM}
+		}
+		return &rootMappingFile{name: name, fs: fs}, nil
+	}
+	realName := fs.realName(name)
+	if err := fs.checkShadowed("open", name, realName); err != nil {
+		return nil, err
+	}
+	f, err := fs.Fs.OpenFile(realName, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &rootMappingFile{File: f, name: name, fs: fs}, nil
+}
+
 // LstatIfPossible returns the os.FileInfo structure describing a given file.
 // It attempts to use Lstat if supported or defers to the os.  In addition to
 // the FileInfo, a boolean is returned telling whether Lstat was called.
@@ -137,39 +278,417 @@ func (fs *RootMappingFs) LstatIfPossible(name string) (os.FileInfo, bool, error)
 	if fs.isRoot(name) {
 		return newRootMappingDirFileInfo(name), false, nil
 	}
+	virtualName := name
 	name = fs.realName(name)
 
+	var (
+		fi  os.FileInfo
+		b   bool
+		err error
+	)
+
 	if ls, ok := fs.Fs.(afero.Lstater); ok {
-		fi, b, err := ls.LstatIfPossible(name)
-		return &realFilenameInfo{FileInfo: fi, realFilename: name}, b, err
+		fi, b, err = ls.LstatIfPossible(name)
+	} else {
+		// The name is already mapped to the real name, so we
+		// need to bypass our own Stat.
+		fi, err = fs.Fs.Stat(name)
+	}
+
+	if err != nil {
+		return nil, b, err
+	}
+
+	if fs.isShadowed(virtualName, fi) {
+		return nil, b, &os.PathError{Op: "lstat", Path: virtualName, Err: os.ErrNotExist}
+	}
+
+	if rfi, ok := fi.(RealFilenameInfo); ok {
+		return rfi, b, nil
+	}
+
+	return &realFilenameInfo{FileInfo: fi, realFilename: name}, b, nil
+}
+
+// DeadOverrides returns the files below the given virtual root that do not
+// exist in any of the other virtual roots, i.e. files that override nothing.
+// The returned filenames are relative to the given root.
+// This is typically used to detect typos in a project's override of a theme file.
+func (fs *RootMappingFs) DeadOverrides(root string) ([]string, error) {
+	root = filepath.Clean(root)
+
+	var others []string
+	for _, vr := range fs.virtualRoots {
+		if vr != root {
+			others = append(others, vr)
+		}
+	}
+
+	var dead []string
+
+	err := afero.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(path, root), filepathSeparator)
+
+		for _, other := range others {
+			if _, err := fs.Stat(filepath.Join(other, rel)); err == nil {
+				return nil
+			}
+		}
+
+		dead = append(dead, rel)
+
+		return nil
+	})
+
+	return dead, err
+}
+
+// ShadowInfo describes a project file shadowed by a file with the same name
+// in a virtual root with higher precedence.
+type ShadowInfo struct {
+	// The filename relative to the virtual roots.
+	Path string
+
+	// The virtual root of the file that wins.
+	Root string
+
+	// The real filename of the file that wins.
+	Filename string
+}
+
+// ModuleShadowsProject returns the files below the given project root that are
+// shadowed by a file in another virtual root. The virtual roots take precedence
+// in the order given to NewRootMappingFs, so the project root is normally
+// the first; anything reported here is most likely a misconfiguration.
+func (fs *RootMappingFs) ModuleShadowsProject(projectRoot string) ([]ShadowInfo, error) {
+	projectRoot = filepath.Clean(projectRoot)
+
+	var higher []string
+	for _, vr := range fs.virtualRoots {
+		if vr == projectRoot {
+			break
+		}
+		higher = append(higher, vr)
+	}
+
+	if len(higher) == 0 {
+		return nil, nil
+	}
+
+	var shadowed []ShadowInfo
+
+	err := afero.Walk(fs, projectRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(path, projectRoot), filepathSeparator)
+
+		for _, vr := range higher {
+			name := filepath.Join(vr, rel)
+			if _, err := fs.Stat(name); err == nil {
+				shadowed = append(shadowed, ShadowInfo{Path: rel, Root: vr, Filename: fs.realName(name)})
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	return shadowed, err
+}
+
+// AllFilenames returns the real filenames of the given path, relative to the
+// virtual roots, in all of the virtual roots where it exists. The filenames
+// are returned in precedence order, i.e. the order given to NewRootMappingFs,
+// so the first is the one that wins.
+func (fs *RootMappingFs) AllFilenames(name string) []string {
+	var filenames []string
+
+	for _, vr := range fs.virtualRoots {
+		vname := filepath.Join(vr, name)
+		if fi, err := fs.Stat(vname); err == nil && !fi.IsDir() {
+			filenames = append(filenames, fs.realName(vname))
+		}
+	}
+
+	return filenames
+}
+
+// GlobFileInfo is a file matched by RootMappingFs.Glob.
+type GlobFileInfo struct {
+	RealFilenameInfo
+
+	// The virtual root the file was found in.
+	VirtualRoot string
+
+	// The slash separated path relative to VirtualRoot, e.g. "_default/single.html".
+	Path string
+}
+
+// Glob returns the files with a path relative to the virtual roots matching
+// the given Glob pattern, e.g. "partials/**/*.html". If the same relative path
+// exists below more than one virtual root, only the one with the highest
+// precedence, i.e. the first in the order given to NewRootMappingFs, is
+// returned. The files are returned in walk order, one virtual root at a time.
+// See https://github.com/gobwas/glob for the full rules set.
+func (fs *RootMappingFs) Glob(pattern string) ([]GlobFileInfo, error) {
+	g, err := glob.Compile(pattern, '/')
+	if err != nil {
+		return nil, err
+	}
+
+	var result []GlobFileInfo
+	seen := make(map[string]bool)
+
+	for _, vr := range fs.virtualRoots {
+		err := afero.Walk(fs, vr, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			rel := strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(path, vr)), "/")
+
+			if seen[rel] || !g.Match(rel) {
+				return nil
+			}
+			seen[rel] = true
+
+			rfi, ok := info.(RealFilenameInfo)
+			if !ok {
+				rfi = &realFilenameInfo{FileInfo: info, realFilename: fs.realName(path)}
+			}
+
+			result = append(result, GlobFileInfo{RealFilenameInfo: rfi, VirtualRoot: vr, Path: rel})
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// CanonicalCase returns the given virtual path with the casing used in the
+// directory entries, e.g. "blog/post.md" for "Blog/Post.md". This is useful on
+// case-insensitive filesystems, where both will resolve to the same file.
+// An exact match wins over a case-insensitive one.
+func (fs *RootMappingFs) CanonicalCase(virtualPath string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(strings.Trim(filepath.Clean(virtualPath), filepathSeparator), filepathSeparator) {
+		if part != "." {
+			parts = append(parts, part)
+		}
+	}
+
+	var canonical string
+
+	for len(parts) > 0 {
+		dir, err := fs.Open(canonical)
+		if err != nil {
+			return "", err
+		}
+		names, err := dir.Readdirnames(-1)
+		dir.Close()
+		if err != nil {
+			return "", err
+		}
+
+		// A nested virtual root, e.g. "content/en", is listed as one name
+		// in the root, so match it element by element.
+		var match []string
+		for _, name := range names {
+			elements := strings.Split(name, filepathSeparator)
+			if len(elements) > len(parts) {
+				continue
+			}
+			isExact, isFold := true, true
+			for i, element := range elements {
+				if element != parts[i] {
+					isExact = false
+					isFold = isFold && strings.EqualFold(element, parts[i])
+				}
+			}
+			if isExact {
+				match = elements
+				break
+			}
+			if isFold && match == nil {
+				match = elements
+			}
+		}
+
+		if match == nil {
+			return "", &os.PathError{Op: "stat", Path: virtualPath, Err: os.ErrNotExist}
+		}
+
+		canonical = filepath.Join(append([]string{canonical}, match...)...)
+		parts = parts[len(match):]
 	}
-	fi, err := fs.Stat(name)
-	return fi, false, err
+
+	return canonical, nil
 }
 
 func (fs *RootMappingFs) realName(name string) string {
-	key, val, found := fs.rootMapToReal.LongestPrefix([]byte(filepath.Clean(name)))
+	if fs.FoldCase {
+		if realName, ok := fs.realNameFold(name); ok {
+			return realName
+		}
+	}
+
+	key, val, found := longestRootPrefix(fs.rootMapToReal, filepath.Clean(name))
 	if !found {
 		return name
 	}
-	keystr := string(key)
 
-	return filepath.Join(val.(string), strings.TrimPrefix(name, keystr))
+	return filepath.Join(val.(string), strings.TrimPrefix(filepath.Clean(name), key))
+}
+
+// longestRootPrefix works as LongestPrefix in the radix tree, but only matches
+// whole path elements, so a virtual root of "content/blog" matches
+// "content/blog/post.md", but not "content/blogging/post.md".
+func longestRootPrefix(tree *radix.Node, name string) (string, interface{}, bool) {
+	var (
+		key   string
+		val   interface{}
+		found bool
+	)
+
+	tree.WalkPath([]byte(name), func(k []byte, v interface{}) bool {
+		ks := string(k)
+		if len(ks) == len(name) || name[len(ks)] == filepath.Separator {
+			key, val, found = ks, v, true
+		}
+		return false
+	})
+
+	return key, val, found
+}
+
+// isShadowed reports whether the file name, with fi from the underlying
+// filesystem, is hidden as described in HideShadowed.
+func (fs *RootMappingFs) isShadowed(name string, fi os.FileInfo) bool {
+	if !fs.HideShadowed || fi.IsDir() {
+		return false
+	}
+
+	name = filepath.Clean(name)
+	key, _, found := longestRootPrefix(fs.rootMapToReal, name)
+	if !found {
+		return false
+	}
+	rel := name[len(key):]
+
+	for _, vr := range fs.virtualRoots {
+		if vr == key {
+			return false
+		}
+		if fi, err := fs.Fs.Stat(fs.realName(vr + rel)); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkShadowed returns a not exist error if the file name, with the given
+// real filename, is hidden as described in HideShadowed.
+func (fs *RootMappingFs) checkShadowed(op, name, realName string) error {
+	if !fs.HideShadowed {
+		return nil
+	}
+	if fi, err := fs.Fs.Stat(realName); err == nil && fs.isShadowed(name, fi) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+func (fs *RootMappingFs) realNameFold(name string) (string, bool) {
+	name = filepath.Clean(name)
+	lower := strings.ToLower(name)
+	if len(lower) != len(name) {
+		// Lower casing changed the byte length, so the key length cannot
+		// be used to split the name below.
+		return "", false
+	}
+
+	key, val, found := longestRootPrefix(fs.rootMapToRealFold, lower)
+	if !found {
+		return "", false
+	}
+
+	return filepath.Join(val.(string), name[len(key):]), true
 }
 
+// Readdir works as in os.File. For the root, the virtual roots are returned
+// in the order given to NewRootMappingFs, the same order as in Mounts.
 func (f *rootMappingFile) Readdir(count int) ([]os.FileInfo, error) {
 	if f.File == nil {
-		dirsn := make([]os.FileInfo, 0)
-		for i := 0; i < len(f.fs.virtualRoots); i++ {
-			if count != -1 && i >= count {
-				break
+		roots := f.fs.virtualRoots[f.offset:]
+		if count > 0 {
+			if len(roots) == 0 {
+				return nil, io.EOF
+			}
+			if count < len(roots) {
+				roots = roots[:count]
 			}
-			dirsn = append(dirsn, newRootMappingDirFileInfo(f.fs.virtualRoots[i]))
+		}
+		f.offset += len(roots)
+
+		dirsn := make([]os.FileInfo, len(roots))
+		for i, vr := range roots {
+			dirsn[i] = newRootMappingDirFileInfo(vr)
 		}
 		return dirsn, nil
 	}
-	return f.File.Readdir(count)
 
+	var (
+		fis []os.FileInfo
+		err error
+	)
+	if f.fs.HideShadowed {
+		fis, err = filteredReaddir(f.File, count, func(fi os.FileInfo) bool {
+			return !f.fs.isShadowed(filepath.Join(f.name, fi.Name()), fi)
+		})
+	} else {
+		fis, err = f.File.Readdir(count)
+	}
+
+	if f.fs.ResolveSymlinks {
+		dirname := f.fs.realName(f.name)
+		for i, fi := range fis {
+			if fi.Mode()&os.ModeSymlink == 0 {
+				continue
+			}
+			if tfi, err := f.fs.Fs.Stat(filepath.Join(dirname, fi.Name())); err == nil {
+				fis[i] = tfi
+			}
+		}
+	}
+
+	return fis, err
 }
 
 func (f *rootMappingFile) Readdirnames(count int) ([]string, error) {
@@ -188,6 +707,83 @@ func (f *rootMappingFile) Name() string {
 	return f.name
 }
 
+// The methods below make the root behave as a directory when it is used as a
+// regular file, e.g. by helpers probing with Seek, instead of panicking.
+
+// Read works as in os.File. For the root, it returns io.EOF.
+func (f *rootMappingFile) Read(p []byte) (int, error) {
+	if f.File == nil {
+		return 0, io.EOF
+	}
+	return f.File.Read(p)
+}
+
+// ReadAt works as in os.File. For the root, it returns io.EOF.
+func (f *rootMappingFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.File == nil {
+		return 0, io.EOF
+	}
+	return f.File.ReadAt(p, off)
+}
+
+// Seek works as in os.File. For the root, seeking to the start starts over
+// reading the virtual roots in Readdir, anything else is a no-op.
+func (f *rootMappingFile) Seek(offset int64, whence int) (int64, error) {
+	if f.File == nil {
+		if offset == 0 && whence == io.SeekStart {
+			f.offset = 0
+		}
+		return 0, nil
+	}
+	return f.File.Seek(offset, whence)
+}
+
+func (f *rootMappingFile) Stat() (os.FileInfo, error) {
+	if f.File == nil {
+		return newRootMappingDirFileInfo(f.name), nil
+	}
+	return f.File.Stat()
+}
+
+func (f *rootMappingFile) Sync() error {
+	if f.File == nil {
+		return nil
+	}
+	return f.File.Sync()
+}
+
+func (f *rootMappingFile) Truncate(size int64) error {
+	if f.File == nil {
+		return f.errRootWrite("truncate")
+	}
+	return f.File.Truncate(size)
+}
+
+func (f *rootMappingFile) Write(p []byte) (int, error) {
+	if f.File == nil {
+		return 0, f.errRootWrite("write")
+	}
+	return f.File.Write(p)
+}
+
+func (f *rootMappingFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.File == nil {
+		return 0, f.errRootWrite("write")
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *rootMappingFile) WriteString(s string) (int, error) {
+	if f.File == nil {
+		return 0, f.errRootWrite("write")
+	}
+	return f.File.WriteString(s)
+}
+
+func (f *rootMappingFile) errRootWrite(op string) error {
+	return &os.PathError{Op: op, Path: f.name, Err: syscall.EPERM}
+}
+
 func (f *rootMappingFile) Close() error {
 	if f.File == nil {
 		return nil
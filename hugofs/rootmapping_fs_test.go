@@ -14,12 +14,19 @@
 package hugofs
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/gohugoio/hugo/htesting/tempdir"
 	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,6 +41,136 @@ func TestRootMappingFsRealName(t *testing.T) {
 
 }
 
+func TestRootMappingFsPrefixBoundary(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("blogt/post.md"), []byte("blog"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("bloggingt/post.md"), []byte("blogging"), 0755))
+
+	rfs, err := NewRootMappingFs(fs, filepath.FromSlash("content/blog"), "blogt", filepath.FromSlash("content/blogging"), "bloggingt")
+	assert.NoError(err)
+
+	assert.Equal(filepath.FromSlash("blogt/post.md"), rfs.realName(filepath.FromSlash("content/blog/post.md")))
+	assert.Equal(filepath.FromSlash("bloggingt/post.md"), rfs.realName(filepath.FromSlash("content/blogging/post.md")))
+
+	for _, test := range []struct {
+		name     string
+		expected string
+	}{
+		{"content/blog/post.md", "blog"},
+		{"content/blogging/post.md", "blogging"},
+	} {
+		b, err := afero.ReadFile(rfs, filepath.FromSlash(test.name))
+		assert.NoError(err, test.name)
+		assert.Equal(test.expected, string(b), test.name)
+	}
+
+	// Only the blog mapping. A plain prefix match would resolve
+	// content/blogging/post.md to blogt/ging/post.md.
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("blogt/ging/post.md"), []byte("wrong"), 0755))
+	rfs, err = NewRootMappingFs(fs, filepath.FromSlash("content/blog"), "blogt")
+	assert.NoError(err)
+
+	_, err = rfs.Stat(filepath.FromSlash("content/blogging/post.md"))
+	assert.True(os.IsNotExist(err))
+	_, err = rfs.Stat(filepath.FromSlash("content/blogextra"))
+	assert.True(os.IsNotExist(err))
+
+	rfs.FoldCase = true
+	_, err = rfs.Stat(filepath.FromSlash("Content/Blogging/post.md"))
+	assert.True(os.IsNotExist(err))
+	_, err = rfs.Stat(filepath.FromSlash("Content/Blog/post.md"))
+	assert.NoError(err)
+}
+
+func TestRootMappingFsFoldCase(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("themes/a/layouts/partials/header.html"), []byte("header"), 0755))
+
+	rfs, err := NewRootMappingFs(fs, filepath.FromSlash("layouts/partials"), filepath.FromSlash("themes/a/layouts/partials"))
+	assert.NoError(err)
+
+	_, err = rfs.Stat(filepath.FromSlash("Layouts/Partials/header.html"))
+	assert.True(os.IsNotExist(err))
+
+	rfs.FoldCase = true
+
+	for _, name := range []string{"layouts/partials/header.html", "Layouts/Partials/header.html", "LAYOUTS/partials/header.html"} {
+		name = filepath.FromSlash(name)
+		fi, err := rfs.Stat(name)
+		assert.NoError(err, name)
+		assert.Equal("header.html", fi.Name())
+		assert.Equal(filepath.FromSlash("themes/a/layouts/partials/header.html"), fi.(RealFilenameInfo).RealFilename())
+		b, err := afero.ReadFile(rfs, name)
+		assert.NoError(err, name)
+		assert.Equal("header", string(b))
+	}
+
+	// The virtual roots keep their case.
+	root, err := rfs.Open("")
+	assert.NoError(err)
+	names, err := root.Readdirnames(-1)
+	assert.NoError(err)
+	assert.Equal([]string{filepath.FromSlash("layouts/partials")}, names)
+}
+
+func TestRootMappingFsRootAsFile(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	rfs, err := NewRootMappingFs(fs, "a", "at", "b", "bt")
+	assert.NoError(err)
+
+	root, err := rfs.Open("")
+	assert.NoError(err)
+	defer root.Close()
+
+	names, err := root.Readdirnames(-1)
+	assert.NoError(err)
+	assert.Equal([]string{"a", "b"}, names)
+
+	// Start over.
+	n, err := root.Seek(0, io.SeekStart)
+	assert.NoError(err)
+	assert.Equal(int64(0), n)
+	names, err = root.Readdirnames(-1)
+	assert.NoError(err)
+	assert.Equal([]string{"a", "b"}, names)
+
+	_, err = root.Seek(10, io.SeekCurrent)
+	assert.NoError(err)
+
+	b := make([]byte, 10)
+	n2, err := root.Read(b)
+	assert.Equal(io.EOF, err)
+	assert.Equal(0, n2)
+	_, err = root.ReadAt(b, 0)
+	assert.Equal(io.EOF, err)
+
+	fi, err := root.Stat()
+	assert.NoError(err)
+	assert.True(fi.IsDir())
+	assert.Equal(int64(0), fi.Size())
+	assert.True(fi.ModTime().IsZero())
+
+	fi, err = rfs.Stat("")
+	assert.NoError(err)
+	assert.Equal(int64(0), fi.Size())
+	assert.True(fi.ModTime().IsZero())
+
+	assert.NoError(root.Sync())
+	_, err = root.Write([]byte("a"))
+	assert.Error(err)
+	_, err = root.WriteString("a")
+	assert.Error(err)
+	_, err = root.WriteAt([]byte("a"), 0)
+	assert.Error(err)
+	assert.Error(root.Truncate(0))
+}
+
 func TestRootMappingFsDirnames(t *testing.T) {
 	assert := require.New(t)
 	fs := afero.NewMemMapFs()
@@ -59,17 +196,96 @@ func TestRootMappingFsDirnames(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal([]string{"bf1", "cf2", "af3"}, dirnames)
 
+	// The order is the same in batches, in Readdir and in Mounts.
+	root, err = rfs.Open(filepathSeparator)
+	assert.NoError(err)
+	dirnames, err = root.Readdirnames(2)
+	assert.NoError(err)
+	assert.Equal([]string{"bf1", "cf2"}, dirnames)
+	dirnames, err = root.Readdirnames(2)
+	assert.NoError(err)
+	assert.Equal([]string{"af3"}, dirnames)
+	_, err = root.Readdirnames(2)
+	assert.Equal(io.EOF, err)
+
+	root, err = rfs.Open(filepathSeparator)
+	assert.NoError(err)
+	fis, err := root.Readdir(0)
+	assert.NoError(err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	assert.Equal([]string{"bf1", "cf2", "af3"}, names)
+
+	names = nil
+	for _, m := range rfs.Mounts() {
+		names = append(names, m.From)
+	}
+	assert.Equal([]string{"bf1", "cf2", "af3"}, names)
+}
+
+func TestRootMappingFsMounts(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	rfs, err := NewRootMappingFs(fs, "bf1", "f1t", "cf2/", "f2t", "af3", filepath.FromSlash("/my/f3t"))
+	assert.NoError(err)
+
+	expected := []RootMapping{
+		{From: "bf1", To: "f1t"},
+		{From: "cf2", To: "f2t"},
+		{From: "af3", To: filepath.FromSlash("/my/f3t")},
+	}
+
+	mounts := rfs.Mounts()
+	assert.Equal(expected, mounts)
+
+	// The mounts are a copy.
+	mounts[0].To = "changed"
+	assert.Equal(expected, rfs.Mounts())
+	assert.Equal(filepath.FromSlash("f1t/myfile.txt"), rfs.realName(filepath.FromSlash("bf1/myfile.txt")))
+}
+
+func TestRootMappingFsAmbiguousRoot(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+	assert.NoError(afero.WriteFile(fs, filepath.Join("f1t", "a.txt"), []byte("f1t"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.Join("f3t", "a.txt"), []byte("f3t"), 0755))
+
+	var log bytes.Buffer
+	jww.SetLogOutput(&log)
+	defer jww.SetLogOutput(ioutil.Discard)
+
+	// The last mapping wins, with a warning.
+	rfs, err := NewRootMappingFs(fs, "bf1", "f1t", "cf2", "f2t", "bf1/", "f3t")
+	assert.NoError(err)
+	assert.Contains(log.String(), `WARN`)
+	assert.Contains(log.String(), `ambiguous root mapping: "bf1" maps to "f1t" and "f3t"`)
+	assert.Equal([]RootMapping{{From: "bf1", To: "f3t"}, {From: "cf2", To: "f2t"}}, rfs.Mounts())
+	b, err := afero.ReadFile(rfs, filepath.Join("bf1", "a.txt"))
+	assert.NoError(err)
+	assert.Equal("f3t", string(b))
+
+	err = &AmbiguousRootError{Mappings: []RootMapping{{From: "bf1", To: "f1t"}, {From: "bf1", To: "f3t"}}}
+	assert.True(errors.Is(err, ErrAmbiguousRoot))
+	var aerr *AmbiguousRootError
+	assert.True(errors.As(err, &aerr))
+
+	// Nested roots are fine.
+	log.Reset()
+	_, err = NewRootMappingFs(fs, "bf1", "f1t", filepath.FromSlash("bf1/sub"), "f3t")
+	assert.NoError(err)
+	assert.Empty(log.String())
 }
 
 func TestRootMappingFsOs(t *testing.T) {
 	assert := require.New(t)
 	fs := afero.NewOsFs()
 
-	d, err := ioutil.TempDir("", "hugo-root-mapping")
+	d, clean, err := tempdir.Create(fs, "hugo-root-mapping")
 	assert.NoError(err)
-	defer func() {
-		os.RemoveAll(d)
-	}()
+	defer clean()
 
 	testfile := "myfile.txt"
 	assert.NoError(fs.Mkdir(filepath.Join(d, "f1t"), 0755))
@@ -92,3 +308,416 @@ func TestRootMappingFsOs(t *testing.T) {
 	assert.Equal([]string{"bf1", "cf2", "af3"}, dirnames)
 
 }
+
+func TestRootMappingFsLstatIfPossible(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestRootMappingFsLstatIfPossible as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	d, clean, err := tempdir.Create(Os, "hugo-root-mapping-lstat")
+	assert.NoError(err)
+	defer clean()
+
+	assert.NoError(os.Mkdir(filepath.Join(d, "f1t"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(d, "f1t", "file.txt"), []byte("some content"), 0755))
+	assert.NoError(os.Symlink(filepath.Join(d, "f1t", "file.txt"), filepath.Join(d, "f1t", "symlink.txt")))
+
+	for _, test := range []struct {
+		name    string
+		fs      afero.Fs
+		isLstat bool
+	}{
+		{"Os", afero.NewOsFs(), true},
+		{"No Lstat", NewNoLstatFs(afero.NewOsFs()), false},
+	} {
+		rfs, err := NewRootMappingFs(test.fs, "bf1", filepath.Join(d, "f1t"))
+		assert.NoError(err)
+
+		fi, ok, err := rfs.LstatIfPossible(filepath.Join("bf1", "symlink.txt"))
+		assert.NoError(err, test.name)
+		assert.Equal(test.isLstat, ok, test.name)
+		assert.Equal(test.isLstat, fi.Mode()&os.ModeSymlink != 0, test.name)
+		assert.Equal(filepath.Join(d, "f1t", "symlink.txt"), fi.(RealFilenameInfo).RealFilename(), test.name)
+
+		_, _, err = rfs.LstatIfPossible(filepath.Join("bf1", "nope.txt"))
+		assert.True(os.IsNotExist(err), test.name)
+	}
+}
+
+func TestRootMappingFsResolveSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestRootMappingFsResolveSymlinks as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	d, clean, err := tempdir.Create(Os, "hugo-root-mapping-resolve-symlinks")
+	assert.NoError(err)
+	defer clean()
+
+	assert.NoError(os.MkdirAll(filepath.Join(d, "f1t", "sub"), 0755))
+	assert.NoError(os.Mkdir(filepath.Join(d, "other"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(d, "f1t", "file.txt"), []byte("some content"), 0755))
+	assert.NoError(os.Symlink(filepath.Join(d, "other"), filepath.Join(d, "f1t", "dirsym")))
+	assert.NoError(os.Symlink(filepath.Join(d, "f1t", "file.txt"), filepath.Join(d, "f1t", "filesym.txt")))
+	assert.NoError(os.Symlink(filepath.Join(d, "nope"), filepath.Join(d, "f1t", "broken")))
+
+	readdir := func(resolve bool) map[string]os.FileInfo {
+		rfs, err := NewRootMappingFs(Os, "bf1", filepath.Join(d, "f1t"))
+		assert.NoError(err)
+		rfs.ResolveSymlinks = resolve
+		fis, err := afero.ReadDir(rfs, "bf1")
+		assert.NoError(err)
+		m := make(map[string]os.FileInfo)
+		for _, fi := range fis {
+			m[fi.Name()] = fi
+		}
+		return m
+	}
+
+	fis := readdir(false)
+	assert.Len(fis, 5)
+	assert.False(fis["dirsym"].IsDir())
+	assert.True(fis["filesym.txt"].Mode()&os.ModeSymlink != 0)
+
+	fis = readdir(true)
+	assert.Len(fis, 5)
+	assert.True(fis["dirsym"].IsDir())
+	assert.True(fis["sub"].IsDir())
+	assert.True(fis["filesym.txt"].Mode().IsRegular())
+	assert.Equal(int64(len("some content")), fis["filesym.txt"].Size())
+	assert.True(fis["broken"].Mode()&os.ModeSymlink != 0)
+}
+
+func TestRootMappingFsEvalSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestRootMappingFsEvalSymlinks as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	d, clean, err := tempdir.Create(Os, "hugo-root-mapping-symlinks")
+	assert.NoError(err)
+	defer clean()
+
+	// The temp dir may itself be behind a symlink, e.g. on macOS.
+	d, err = filepath.EvalSymlinks(d)
+	assert.NoError(err)
+
+	assert.NoError(os.Mkdir(filepath.Join(d, "f1t"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(d, "f1t", "file.txt"), []byte("some content"), 0755))
+	assert.NoError(os.Symlink(filepath.Join(d, "f1t"), filepath.Join(d, "f1tsym")))
+
+	rfs, err := NewRootMappingFsEvalSymlinks(Os, "bf1", filepath.Join(d, "f1tsym"), "bf2", filepath.Join(d, "nope"))
+	assert.NoError(err)
+	assert.Equal([]RootMapping{
+		{From: "bf1", To: filepath.Join(d, "f1t")},
+		{From: "bf2", To: filepath.Join(d, "nope")},
+	}, rfs.Mounts())
+
+	fi, err := rfs.Stat(filepath.Join("bf1", "file.txt"))
+	assert.NoError(err)
+	assert.Equal(filepath.Join(d, "f1t", "file.txt"), fi.(RealFilenameInfo).RealFilename())
+
+	// Not resolved.
+	rfs, err = NewRootMappingFs(Os, "bf1", filepath.Join(d, "f1tsym"))
+	assert.NoError(err)
+	assert.Equal(filepath.Join(d, "f1tsym"), rfs.Mounts()[0].To)
+}
+
+func TestRootMappingFsDeadOverrides(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{
+		"project/partials/header.html",
+		"project/partials/foter.html",
+		"project/_default/single.html",
+		"mytheme/partials/header.html",
+		"mytheme/partials/footer.html",
+		"othertheme/_default/single.html",
+	} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("some content"), 0755))
+	}
+
+	rfs, err := NewRootMappingFs(fs, "p", "project", "t1", "mytheme", "t2", "othertheme")
+	assert.NoError(err)
+
+	dead, err := rfs.DeadOverrides("p")
+	assert.NoError(err)
+	assert.Equal([]string{filepath.FromSlash("partials/foter.html")}, dead)
+
+	dead, err = rfs.DeadOverrides("t2")
+	assert.NoError(err)
+	assert.Empty(dead)
+}
+
+func TestRootMappingFsModuleShadowsProject(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{
+		"project/partials/header.html",
+		"project/partials/footer.html",
+		"project/_default/single.html",
+		"mytheme/partials/header.html",
+		"othertheme/partials/footer.html",
+	} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("some content"), 0755))
+	}
+
+	rfs, err := NewRootMappingFs(fs, "t1", "mytheme", "p", "project", "t2", "othertheme")
+	assert.NoError(err)
+
+	shadowed, err := rfs.ModuleShadowsProject("p")
+	assert.NoError(err)
+	assert.Equal([]ShadowInfo{
+		{Path: filepath.FromSlash("partials/header.html"), Root: "t1", Filename: filepath.FromSlash("mytheme/partials/header.html")},
+	}, shadowed)
+
+	// Project first.
+	rfs, err = NewRootMappingFs(fs, "p", "project", "t1", "mytheme", "t2", "othertheme")
+	assert.NoError(err)
+	shadowed, err = rfs.ModuleShadowsProject("p")
+	assert.NoError(err)
+	assert.Empty(shadowed)
+}
+
+func TestRootMappingFsAllFilenames(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{
+		"project/partials/header.html",
+		"project/_default/single.html",
+		"mytheme/partials/header.html",
+		"mytheme/partials/footer.html",
+		"othertheme/partials/header.html",
+	} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("some content"), 0755))
+	}
+
+	rfs, err := NewRootMappingFs(fs, "p", "project", "t1", "mytheme", "t2", "othertheme")
+	assert.NoError(err)
+
+	assert.Equal([]string{
+		filepath.FromSlash("project/partials/header.html"),
+		filepath.FromSlash("mytheme/partials/header.html"),
+		filepath.FromSlash("othertheme/partials/header.html"),
+	}, rfs.AllFilenames(filepath.FromSlash("partials/header.html")))
+	assert.Equal([]string{filepath.FromSlash("mytheme/partials/footer.html")}, rfs.AllFilenames(filepath.FromSlash("partials/footer.html")))
+	assert.Empty(rfs.AllFilenames("partials"))
+	assert.Empty(rfs.AllFilenames(filepath.FromSlash("partials/nope.html")))
+}
+
+func TestRootMappingFsRootOrderIsPrecedence(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	virtualRoots := []string{"zproject", "mtheme", "atheme"}
+	var fromTo []string
+	for _, vr := range virtualRoots {
+		assert.NoError(afero.WriteFile(fs, filepath.Join(vr+"t", "file.txt"), []byte(vr), 0755))
+		fromTo = append(fromTo, vr, vr+"t")
+	}
+
+	rfs, err := NewRootMappingFs(fs, fromTo...)
+	assert.NoError(err)
+
+	var precedence []string
+	for _, filename := range rfs.AllFilenames("file.txt") {
+		precedence = append(precedence, strings.TrimSuffix(filepath.Dir(filename), "t"))
+	}
+	assert.Equal(virtualRoots, precedence)
+
+	for i := 0; i < 3; i++ {
+		root, err := rfs.Open("")
+		assert.NoError(err)
+		dirnames, err := root.Readdirnames(-1)
+		assert.NoError(err)
+		assert.Equal(virtualRoots, dirnames)
+	}
+}
+
+func TestRootMappingFsHideShadowed(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(fs, filepath.Join("project", "en.toml"), []byte("project"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.Join("theme", "en.toml"), []byte("theme"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.Join("theme", "sv.toml"), []byte("theme"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.Join("project", "sub", "a.toml"), []byte("project"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.Join("theme", "sub", "a.toml"), []byte("theme"), 0755))
+
+	rfs, err := NewRootMappingFs(fs, "project", "project", "theme", "theme")
+	assert.NoError(err)
+
+	readdirnames := func(name string) []string {
+		f, err := rfs.Open(name)
+		assert.NoError(err)
+		defer f.Close()
+		names, err := f.Readdirnames(-1)
+		assert.NoError(err)
+		return names
+	}
+
+	assert.Equal([]string{"en.toml", "sub", "sv.toml"}, readdirnames("theme"))
+
+	rfs.HideShadowed = true
+
+	assert.Equal([]string{"en.toml", "sub"}, readdirnames("project"))
+	assert.Equal([]string{"sub", "sv.toml"}, readdirnames("theme"))
+	assert.Equal([]string{}, readdirnames(filepath.Join("theme", "sub")))
+
+	b, err := afero.ReadFile(rfs, filepath.Join("project", "en.toml"))
+	assert.NoError(err)
+	assert.Equal("project", string(b))
+
+	for _, name := range []string{filepath.Join("theme", "en.toml"), filepath.Join("theme", "sub", "a.toml")} {
+		_, err = rfs.Stat(name)
+		assert.True(os.IsNotExist(err), name)
+		_, _, err = rfs.LstatIfPossible(name)
+		assert.True(os.IsNotExist(err), name)
+		_, err = rfs.Open(name)
+		assert.True(os.IsNotExist(err), name)
+	}
+
+	_, err = rfs.Stat(filepath.Join("theme", "sv.toml"))
+	assert.NoError(err)
+}
+
+func TestRootMappingFsGlob(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{
+		"project/layouts/_default/single.html",
+		"project/layouts/partials/header.html",
+		"project/layouts/robots.txt",
+		"mytheme/layouts/_default/single.html",
+		"mytheme/layouts/_default/list.html",
+		"mytheme/layouts/partials/nav/menu.html",
+	} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte(filename), 0755))
+	}
+
+	rfs, err := NewRootMappingFs(fs, "p", filepath.FromSlash("project/layouts"), "t", filepath.FromSlash("mytheme/layouts"))
+	assert.NoError(err)
+
+	matches, err := rfs.Glob("**/*.html")
+	assert.NoError(err)
+
+	got := make(map[string]string)
+	for _, m := range matches {
+		got[m.Path] = m.VirtualRoot
+		b, err := afero.ReadFile(fs, m.RealFilename())
+		assert.NoError(err)
+		assert.Equal(filepath.ToSlash(m.RealFilename()), string(b))
+	}
+
+	assert.Equal(map[string]string{
+		"_default/single.html":   "p",
+		"_default/list.html":     "t",
+		"partials/header.html":   "p",
+		"partials/nav/menu.html": "t",
+	}, got)
+	assert.Len(matches, 4)
+
+	single := matches[0]
+	assert.Equal("single.html", single.Name())
+	assert.Equal(filepath.FromSlash("project/layouts/_default/single.html"), single.RealFilename())
+
+	matches, err = rfs.Glob("partials/**")
+	assert.NoError(err)
+	assert.Len(matches, 2)
+
+	_, err = rfs.Glob("[")
+	assert.Error(err)
+}
+
+func TestRootMappingFsCanonicalCase(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{
+		"project/blog/post.md",
+		"project/blog/Page.md",
+		"project/blog/page.md",
+	} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("some content"), 0755))
+	}
+
+	rfs, err := NewRootMappingFs(fs, "content", "project")
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		virtualPath string
+		expected    string
+	}{
+		{"content/blog/post.md", "content/blog/post.md"},
+		{"Content/Blog/Post.md", "content/blog/post.md"},
+		{"/content/BLOG/", "content/blog"},
+		{"content/blog/PAGE.md", "content/blog/Page.md"},
+		{"content/blog/page.md", "content/blog/page.md"},
+		{"content/blog/nope.md", ""},
+	} {
+		canonical, err := rfs.CanonicalCase(filepath.FromSlash(test.virtualPath))
+		if test.expected == "" {
+			assert.True(os.IsNotExist(err), test.virtualPath)
+			continue
+		}
+		assert.NoError(err)
+		assert.Equal(filepath.FromSlash(test.expected), canonical, test.virtualPath)
+	}
+
+	// Nested virtual roots.
+	rfs, err = NewRootMappingFs(fs, filepath.FromSlash("content/en"), "project", filepath.FromSlash("content/sv/blog"), filepath.FromSlash("project/blog"))
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		virtualPath string
+		expected    string
+	}{
+		{"content/en/blog/post.md", "content/en/blog/post.md"},
+		{"Content/EN/Blog/Post.md", "content/en/blog/post.md"},
+		{"CONTENT/SV/BLOG/PAGE.md", "content/sv/blog/Page.md"},
+		{"content/sv/blog", "content/sv/blog"},
+		{"content/de/blog/post.md", ""},
+	} {
+		canonical, err := rfs.CanonicalCase(filepath.FromSlash(test.virtualPath))
+		if test.expected == "" {
+			assert.True(os.IsNotExist(err), test.virtualPath)
+			continue
+		}
+		assert.NoError(err)
+		assert.Equal(filepath.FromSlash(test.expected), canonical, test.virtualPath)
+	}
+}
+
+func TestRootMappingFsOpenFile(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(fs, filepath.Join("f1t", "myfile.txt"), []byte("some content"), 0755))
+
+	rfs, err := NewRootMappingFs(fs, "bf1", "f1t")
+	assert.NoError(err)
+
+	f, err := rfs.OpenFile(filepath.Join("bf1", "myfile.txt"), os.O_RDONLY, 0)
+	assert.NoError(err)
+	b, err := ioutil.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal("some content", string(b))
+	f.Close()
+
+	root, err := rfs.OpenFile(filepathSeparator, os.O_RDONLY, 0)
+	assert.NoError(err)
+	dirnames, err := root.Readdirnames(-1)
+	assert.NoError(err)
+	assert.Equal([]string{"bf1"}, dirnames)
+
+	_, err = rfs.OpenFile(filepathSeparator, os.O_RDWR, 0755)
+	assert.Error(err)
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootMappingFsForwardSlashes(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(fs, `f1t\foo\file.txt`, []byte("some content"), 0755))
+
+	rfs, err := NewRootMappingFs(fs, `static\a`, "f1t")
+	assert.NoError(err)
+
+	for _, name := range []string{"/", "./"} {
+		fi, err := rfs.Stat(name)
+		assert.NoError(err, name)
+		assert.True(fi.IsDir(), name)
+	}
+
+	fi, err := rfs.Stat("static/a/foo/file.txt")
+	assert.NoError(err)
+	assert.Equal(`f1t\foo\file.txt`, fi.(RealFilenameInfo).RealFilename())
+
+	b, err := afero.ReadFile(rfs, "static/a/foo/file.txt")
+	assert.NoError(err)
+	assert.Equal("some content", string(b))
+}
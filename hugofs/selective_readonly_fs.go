@@ -0,0 +1,138 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var _ afero.Fs = (*selectiveReadOnlyFs)(nil)
+
+type selectiveReadOnlyFs struct {
+	afero.Fs
+	writable []string
+}
+
+// NewSelectiveReadOnlyFs creates a new filesystem that is read-only except for
+// the given directories, e.g. "_gen", and anything below them. Any other write
+// will fail with a *os.PathError wrapping syscall.EPERM. Reads are allowed
+// everywhere.
+func NewSelectiveReadOnlyFs(fs afero.Fs, writablePrefixes []string) afero.Fs {
+	writable := make([]string, len(writablePrefixes))
+	for i, prefix := range writablePrefixes {
+		writable[i] = cleanRel(prefix)
+	}
+	return &selectiveReadOnlyFs{Fs: fs, writable: writable}
+}
+
+// cleanRel returns name cleaned and without any leading separator.
+func cleanRel(name string) string {
+	return strings.TrimPrefix(filepath.Clean(filepathSeparator+name), filepathSeparator)
+}
+
+// isWritable reports whether name is one of or below one of the writable dirs.
+func (fs *selectiveReadOnlyFs) isWritable(name string) bool {
+	name = cleanRel(name)
+	for _, dir := range fs.writable {
+		if dir == "" || name == dir || strings.HasPrefix(name, dir+filepathSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *selectiveReadOnlyFs) check(op, name string) error {
+	if fs.isWritable(name) {
+		return nil
+	}
+	return &os.PathError{Op: op, Path: name, Err: syscall.EPERM}
+}
+
+func (fs *selectiveReadOnlyFs) Create(name string) (afero.File, error) {
+	if err := fs.check("create", name); err != nil {
+		return nil, err
+	}
+	return fs.Fs.Create(name)
+}
+
+func (fs *selectiveReadOnlyFs) Mkdir(name string, perm os.FileMode) error {
+	if err := fs.check("mkdir", name); err != nil {
+		return err
+	}
+	return fs.Fs.Mkdir(name, perm)
+}
+
+func (fs *selectiveReadOnlyFs) MkdirAll(path string, perm os.FileMode) error {
+	if err := fs.check("mkdir", path); err != nil {
+		return err
+	}
+	return fs.Fs.MkdirAll(path, perm)
+}
+
+func (fs *selectiveReadOnlyFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if isWrite(flag) || flag&os.O_CREATE != 0 {
+		if err := fs.check("open", name); err != nil {
+			return nil, err
+		}
+	}
+	return fs.Fs.OpenFile(name, flag, perm)
+}
+
+func (fs *selectiveReadOnlyFs) Remove(name string) error {
+	if err := fs.check("remove", name); err != nil {
+		return err
+	}
+	return fs.Fs.Remove(name)
+}
+
+func (fs *selectiveReadOnlyFs) RemoveAll(path string) error {
+	if err := fs.check("removeall", path); err != nil {
+		return err
+	}
+	return fs.Fs.RemoveAll(path)
+}
+
+func (fs *selectiveReadOnlyFs) Rename(oldname, newname string) error {
+	if err := fs.check("rename", oldname); err != nil {
+		return err
+	}
+	if err := fs.check("rename", newname); err != nil {
+		return err
+	}
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func (fs *selectiveReadOnlyFs) Chmod(name string, mode os.FileMode) error {
+	if err := fs.check("chmod", name); err != nil {
+		return err
+	}
+	return fs.Fs.Chmod(name, mode)
+}
+
+func (fs *selectiveReadOnlyFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := fs.check("chtimes", name); err != nil {
+		return err
+	}
+	return fs.Fs.Chtimes(name, atime, mtime)
+}
+
+func (fs *selectiveReadOnlyFs) Name() string {
+	return "selectiveReadOnlyFs"
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectiveReadOnlyFs(t *testing.T) {
+	assert := require.New(t)
+	base := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(base, filepath.FromSlash("css/main.css"), []byte("main"), 0755))
+	assert.NoError(afero.WriteFile(base, filepath.FromSlash("_gen/images/a.png"), []byte("a"), 0755))
+
+	fs := NewSelectiveReadOnlyFs(base, []string{"/_gen/"})
+
+	isPerm := func(err error) bool {
+		perr, ok := err.(*os.PathError)
+		return ok && perr.Err == syscall.EPERM
+	}
+
+	// Allowed.
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("_gen/images/b.png"), []byte("b"), 0755))
+	assert.NoError(fs.MkdirAll(filepath.FromSlash("_gen/images/sub"), 0755))
+	assert.NoError(fs.Rename(filepath.FromSlash("_gen/images/b.png"), filepath.FromSlash("_gen/images/c.png")))
+	mtime := time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(fs.Chtimes(filepath.FromSlash("_gen/images/c.png"), mtime, mtime))
+	assert.NoError(fs.Remove(filepath.FromSlash("_gen/images/c.png")))
+
+	// Not allowed.
+	assert.True(isPerm(afero.WriteFile(fs, filepath.FromSlash("css/main.css"), []byte("changed"), 0755)))
+	assert.True(isPerm(afero.WriteFile(fs, filepath.FromSlash("_genx/a.png"), []byte("a"), 0755)))
+	_, err := fs.Create("new.txt")
+	assert.True(isPerm(err))
+	assert.True(isPerm(fs.Mkdir("dir", 0755)))
+	assert.True(isPerm(fs.Remove(filepath.FromSlash("css/main.css"))))
+	assert.True(isPerm(fs.RemoveAll("css")))
+	assert.True(isPerm(fs.Rename(filepath.FromSlash("_gen/images/a.png"), "a.png")))
+	assert.True(isPerm(fs.Rename(filepath.FromSlash("css/main.css"), filepath.FromSlash("_gen/main.css"))))
+	assert.True(isPerm(fs.Chmod(filepath.FromSlash("css/main.css"), 0644)))
+
+	// Reads are allowed everywhere.
+	b, err := afero.ReadFile(fs, filepath.FromSlash("css/main.css"))
+	assert.NoError(err)
+	assert.Equal("main", string(b))
+	f, err := fs.OpenFile(filepath.FromSlash("css/main.css"), os.O_RDONLY, 0)
+	assert.NoError(err)
+	f.Close()
+	b, err = afero.ReadFile(fs, filepath.FromSlash("_gen/images/a.png"))
+	assert.NoError(err)
+	assert.Equal("a", string(b))
+}
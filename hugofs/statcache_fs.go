@@ -0,0 +1,228 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	_ afero.Fs      = (*statCacheFs)(nil)
+	_ afero.Lstater = (*statCacheFs)(nil)
+	_ Reseter       = (*statCacheFs)(nil)
+)
+
+type statResult struct {
+	fi      os.FileInfo
+	isLstat bool
+	err     error
+}
+
+// statCacheFs caches the results of Stat and LstatIfPossible.
+type statCacheFs struct {
+	afero.Fs
+
+	mu    sync.RWMutex
+	stat  map[string]statResult
+	lstat map[string]statResult
+}
+
+// NewStatCacheFs creates a new filesystem that memoizes the results of Stat
+// and LstatIfPossible, including any os.IsNotExist errors.
+// Cached entries for a file, its parent directories and, for directories, any
+// file below it, are invalidated when modified through this filesystem,
+// including writes to a file opened with Create or OpenFile.
+// Open and Readdir are passed through unchanged.
+func NewStatCacheFs(fs afero.Fs) afero.Fs {
+	c := &statCacheFs{Fs: fs}
+	c.Reset()
+	return c
+}
+
+// Reset clears the cache.
+func (fs *statCacheFs) Reset() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.stat = make(map[string]statResult)
+	fs.lstat = make(map[string]statResult)
+}
+
+func (fs *statCacheFs) Stat(name string) (os.FileInfo, error) {
+	key := filepath.Clean(name)
+
+	fs.mu.RLock()
+	r, found := fs.stat[key]
+	fs.mu.RUnlock()
+	if found {
+		return r.fi, r.err
+	}
+
+	fi, err := fs.Fs.Stat(name)
+	fs.store(fs.stat, key, statResult{fi: fi, err: err})
+
+	return fi, err
+}
+
+// LstatIfPossible returns the os.FileInfo structure describing a given file.
+// It attempts to use Lstat if supported or defers to the os.  In addition to
+// the FileInfo, a boolean is returned telling whether Lstat was called.
+func (fs *statCacheFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	ls, ok := fs.Fs.(afero.Lstater)
+	if !ok {
+		fi, err := fs.Stat(name)
+		return fi, false, err
+	}
+
+	key := filepath.Clean(name)
+
+	fs.mu.RLock()
+	r, found := fs.lstat[key]
+	fs.mu.RUnlock()
+	if found {
+		return r.fi, r.isLstat, r.err
+	}
+
+	fi, b, err := ls.LstatIfPossible(name)
+	fs.store(fs.lstat, key, statResult{fi: fi, isLstat: b, err: err})
+
+	return fi, b, err
+}
+
+func (fs *statCacheFs) store(m map[string]statResult, key string, r statResult) {
+	if r.err != nil && !os.IsNotExist(r.err) {
+		return
+	}
+
+	fs.mu.Lock()
+	m[key] = r
+	fs.mu.Unlock()
+}
+
+// invalidate removes the given filename, its parents and, if a directory,
+// anything below it from the cache.
+func (fs *statCacheFs) invalidate(name string) {
+	name = filepath.Clean(name)
+	prefix := name + filepathSeparator
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, m := range []map[string]statResult{fs.stat, fs.lstat} {
+		for k := range m {
+			if k == name || strings.HasPrefix(k, prefix) || strings.HasPrefix(name, k+filepathSeparator) || k == "." || k == filepathSeparator {
+				delete(m, k)
+			}
+		}
+	}
+}
+
+func (fs *statCacheFs) Create(name string) (afero.File, error) {
+	defer fs.invalidate(name)
+	f, err := fs.Fs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &statCacheFile{File: f, fs: fs, name: name}, nil
+}
+
+func (fs *statCacheFs) Mkdir(name string, perm os.FileMode) error {
+	defer fs.invalidate(name)
+	return fs.Fs.Mkdir(name, perm)
+}
+
+func (fs *statCacheFs) MkdirAll(path string, perm os.FileMode) error {
+	defer fs.invalidate(path)
+	return fs.Fs.MkdirAll(path, perm)
+}
+
+func (fs *statCacheFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if !isWrite(flag) && flag&os.O_CREATE == 0 {
+		return fs.Fs.OpenFile(name, flag, perm)
+	}
+	defer fs.invalidate(name)
+	f, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &statCacheFile{File: f, fs: fs, name: name}, nil
+}
+
+func (fs *statCacheFs) Remove(name string) error {
+	defer fs.invalidate(name)
+	return fs.Fs.Remove(name)
+}
+
+func (fs *statCacheFs) RemoveAll(path string) error {
+	defer fs.invalidate(path)
+	return fs.Fs.RemoveAll(path)
+}
+
+func (fs *statCacheFs) Rename(oldname, newname string) error {
+	defer fs.invalidate(oldname)
+	defer fs.invalidate(newname)
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func (fs *statCacheFs) Chmod(name string, mode os.FileMode) error {
+	defer fs.invalidate(name)
+	return fs.Fs.Chmod(name, mode)
+}
+
+func (fs *statCacheFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	defer fs.invalidate(name)
+	return fs.Fs.Chtimes(name, atime, mtime)
+}
+
+func (fs *statCacheFs) Name() string {
+	return "statCacheFs"
+}
+
+// statCacheFile invalidates the cached entries for a file opened for writing
+// whenever it is modified, as the size and modification time change.
+type statCacheFile struct {
+	afero.File
+	fs   *statCacheFs
+	name string
+}
+
+func (f *statCacheFile) Write(p []byte) (int, error) {
+	defer f.fs.invalidate(f.name)
+	return f.File.Write(p)
+}
+
+func (f *statCacheFile) WriteAt(p []byte, off int64) (int, error) {
+	defer f.fs.invalidate(f.name)
+	return f.File.WriteAt(p, off)
+}
+
+func (f *statCacheFile) WriteString(s string) (int, error) {
+	defer f.fs.invalidate(f.name)
+	return f.File.WriteString(s)
+}
+
+func (f *statCacheFile) Truncate(size int64) error {
+	defer f.fs.invalidate(f.name)
+	return f.File.Truncate(size)
+}
+
+func (f *statCacheFile) Close() error {
+	defer f.fs.invalidate(f.name)
+	return f.File.Close()
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type statCountingFs struct {
+	afero.Fs
+	stats  int64
+	lstats int64
+}
+
+func (fs *statCountingFs) Stat(name string) (os.FileInfo, error) {
+	atomic.AddInt64(&fs.stats, 1)
+	return fs.Fs.Stat(name)
+}
+
+func (fs *statCountingFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	atomic.AddInt64(&fs.lstats, 1)
+	fi, err := fs.Fs.Stat(name)
+	return fi, false, err
+}
+
+func TestStatCacheFs(t *testing.T) {
+	assert := require.New(t)
+	cfs := &statCountingFs{Fs: afero.NewMemMapFs()}
+	fs := NewStatCacheFs(cfs)
+
+	filename := filepath.FromSlash("a/b/c.txt")
+
+	assert.NoError(afero.WriteFile(fs, filename, []byte("some content"), 0755))
+
+	for i := 0; i < 3; i++ {
+		fi, err := fs.Stat(filename)
+		assert.NoError(err)
+		assert.Equal("c.txt", fi.Name())
+		assert.Equal(int64(1), cfs.stats)
+	}
+
+	for i := 0; i < 3; i++ {
+		fi, ok, err := fs.(afero.Lstater).LstatIfPossible(filename)
+		assert.NoError(err)
+		assert.False(ok)
+		assert.Equal("c.txt", fi.Name())
+		assert.Equal(int64(1), cfs.lstats)
+	}
+
+	// Not found.
+	for i := 0; i < 3; i++ {
+		_, err := fs.Stat("d.txt")
+		assert.True(os.IsNotExist(err))
+		assert.Equal(int64(2), cfs.stats)
+	}
+
+	// Invalidations.
+	assert.NoError(afero.WriteFile(fs, "d.txt", []byte("some content"), 0755))
+	_, err := fs.Stat("d.txt")
+	assert.NoError(err)
+	assert.Equal(int64(3), cfs.stats)
+
+	mtime := time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(fs.Chtimes("d.txt", mtime, mtime))
+	fi, err := fs.Stat("d.txt")
+	assert.NoError(err)
+	assert.Equal(mtime, fi.ModTime().UTC())
+
+	assert.NoError(fs.Chmod("d.txt", 0600))
+	fi, err = fs.Stat("d.txt")
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), fi.Mode().Perm())
+
+	// Writes to an open file.
+	f, err := fs.OpenFile("d.txt", os.O_WRONLY|os.O_APPEND, 0755)
+	assert.NoError(err)
+	_, err = fs.Stat("d.txt")
+	assert.NoError(err)
+	stats := cfs.stats
+	_, err = f.WriteString(" and more")
+	assert.NoError(err)
+	fi, err = fs.Stat("d.txt")
+	assert.NoError(err)
+	assert.Equal(stats+1, cfs.stats)
+	assert.Equal(int64(len("some content and more")), fi.Size())
+	assert.NoError(f.Truncate(4))
+	fi, err = fs.Stat("d.txt")
+	assert.NoError(err)
+	assert.Equal(stats+2, cfs.stats)
+	assert.Equal(int64(4), fi.Size())
+	assert.NoError(f.Close())
+
+	f, err = fs.Create("d.txt")
+	assert.NoError(err)
+	fi, err = fs.Stat("d.txt")
+	assert.NoError(err)
+	assert.Equal(int64(0), fi.Size())
+	_, err = f.Write([]byte("some content"))
+	assert.NoError(err)
+	assert.NoError(f.Close())
+	fi, err = fs.Stat("d.txt")
+	assert.NoError(err)
+	assert.Equal(int64(len("some content")), fi.Size())
+
+	assert.NoError(fs.Rename("d.txt", "e.txt"))
+	_, err = fs.Stat("d.txt")
+	assert.True(os.IsNotExist(err))
+	_, err = fs.Stat("e.txt")
+	assert.NoError(err)
+
+	assert.NoError(fs.Remove("e.txt"))
+	_, err = fs.Stat("e.txt")
+	assert.True(os.IsNotExist(err))
+
+	// Removing a directory invalidates its children.
+	assert.NoError(fs.RemoveAll("a"))
+	_, err = fs.Stat(filename)
+	assert.True(os.IsNotExist(err))
+
+	// Creating a file invalidates its parents.
+	_, err = fs.Stat(filepath.FromSlash("f/g"))
+	assert.True(os.IsNotExist(err))
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("f/g/h.txt"), []byte("some content"), 0755))
+	fi, err = fs.Stat(filepath.FromSlash("f/g"))
+	assert.NoError(err)
+	assert.True(fi.IsDir())
+}
+
+func BenchmarkStatCacheFs(b *testing.B) {
+	newFs := func() *statCountingFs {
+		fs := &statCountingFs{Fs: afero.NewMemMapFs()}
+		for i := 0; i < 10; i++ {
+			afero.WriteFile(fs, fmt.Sprintf("file%d.txt", i), []byte("some content"), 0755)
+		}
+		return fs
+	}
+
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%t", cached), func(b *testing.B) {
+			cfs := newFs()
+			var fs afero.Fs = cfs
+			if cached {
+				fs = NewStatCacheFs(cfs)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < 12; j++ {
+					fs.Stat(fmt.Sprintf("file%d.txt", j))
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&cfs.stats))/float64(b.N), "stats/op")
+		})
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// VirtualWalkFunc is the type of the function called for each file or directory
+// visited by WalkVirtual.
+type VirtualWalkFunc func(virtualPath string, fi os.FileInfo, err error) error
+
+// WalkVirtual walks the file tree rooted at root, calling walkFn for each file
+// or directory in the tree, including root.
+// Unlike afero.Walk, walkFn receives the virtual path, i.e. the path of the file
+// relative to the root of fs (ie. "sect/page.md"), which for a RootMappingFs
+// will include the virtual root, and for a LanguageFs will have any language
+// markers removed. To get the real filename, use RealFilenameInfo.
+func WalkVirtual(fs afero.Fs, root string, walkFn VirtualWalkFunc) error {
+	return afero.Walk(fs, root, func(path string, fi os.FileInfo, err error) error {
+		return walkFn(virtualPath(path, fi), fi, err)
+	})
+}
+
+func virtualPath(path string, fi os.FileInfo) string {
+	if fp, ok := fi.(FilePather); ok {
+		return fp.Path()
+	}
+
+	path = strings.TrimPrefix(filepath.Clean(path), filepathSeparator)
+	if path == "." {
+		return ""
+	}
+
+	return path
+}
+
+// WalkRoots walks the file trees rooted at each of roots in the given order,
+// calling walkFn as afero.Walk would. A root that is the same as or below an
+// already walked root is skipped, as is an already walked root found below
+// another root, so no file is visited twice.
+func WalkRoots(fs afero.Fs, roots []string, walkFn filepath.WalkFunc) error {
+	var walked []string
+
+	for _, root := range roots {
+		root = filepath.Clean(root)
+
+		seen := false
+		for _, dir := range walked {
+			if root == dir || isDescendant(root, dir) {
+				seen = true
+				break
+			}
+		}
+		if seen {
+			continue
+		}
+
+		err := afero.Walk(fs, root, func(path string, fi os.FileInfo, err error) error {
+			if err == nil && fi.IsDir() && path != root {
+				for _, dir := range walked {
+					if filepath.Clean(path) == dir {
+						return filepath.SkipDir
+					}
+				}
+			}
+			return walkFn(path, fi, err)
+		})
+		if err != nil {
+			return err
+		}
+
+		walked = append(walked, root)
+	}
+
+	return nil
+}
+
+// DirEventFunc is the type of the function called when WalkDirEvents enters
+// or leaves a directory.
+type DirEventFunc func(path string, fi os.FileInfo) error
+
+// WalkDirEvents works like afero.Walk, but also calls onDirEnter before walkFn
+// is called for a directory, and onDirLeave when all of its children have been
+// visited (or skipped with filepath.SkipDir), innermost first.
+// Both onDirEnter and onDirLeave may be nil.
+func WalkDirEvents(fs afero.Fs, root string, walkFn filepath.WalkFunc, onDirEnter, onDirLeave DirEventFunc) error {
+	type dir struct {
+		path string
+		fi   os.FileInfo
+	}
+
+	var stack []dir
+
+	// leave leaves all directories on the stack that are not an ancestor of
+	// path, or all if all is set.
+	leave := func(path string, all bool) error {
+		for len(stack) > 0 {
+			d := stack[len(stack)-1]
+			if !all && isDescendant(path, d.path) {
+				return nil
+			}
+			stack = stack[:len(stack)-1]
+			if onDirLeave != nil {
+				if err := onDirLeave(d.path, d.fi); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	err := afero.Walk(fs, root, func(path string, fi os.FileInfo, err error) error {
+		if lerr := leave(path, false); lerr != nil {
+			return lerr
+		}
+
+		if err == nil && fi != nil && fi.IsDir() {
+			stack = append(stack, dir{path: path, fi: fi})
+			if onDirEnter != nil {
+				if err := onDirEnter(path, fi); err != nil {
+					return err
+				}
+			}
+		}
+
+		return walkFn(path, fi, err)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return leave("", true)
+}
+
+// isDescendant reports whether path is below dir.
+func isDescendant(path, dir string) bool {
+	if dir != "" && !strings.HasSuffix(dir, filepathSeparator) {
+		dir += filepathSeparator
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+// WalkFiles walks the file tree rooted at root, calling fn for each file, not
+// directory, where match returns true. A nil match matches all files.
+// All directories are walked. Any error walking the tree is passed on to fn.
+func WalkFiles(fs afero.Fs, root string, match func(fi os.FileInfo) bool, fn filepath.WalkFunc) error {
+	return afero.Walk(fs, root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return fn(path, fi, err)
+		}
+
+		if fi.IsDir() || (match != nil && !match(fi)) {
+			return nil
+		}
+
+		return fn(path, fi, nil)
+	})
+}
+
+// WalkDirFilter works like afero.Walk, but any directory for which dirFilter
+// returns false is skipped before it is opened, e.g. to avoid reading a big
+// node_modules directory, and walkFn is not called for it or anything below it.
+// This also applies to root.
+func WalkDirFilter(fs afero.Fs, root string, dirFilter func(path string, fi os.FileInfo) bool, walkFn filepath.WalkFunc) error {
+	return afero.Walk(fs, root, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && fi.IsDir() && !dirFilter(path, fi) {
+			return filepath.SkipDir
+		}
+		return walkFn(path, fi, err)
+	})
+}
+
+// WalkPostOrder works like afero.Walk, but walkFn is called for a directory
+// after everything below it, e.g. to delete empty directories. Directories are
+// skipped with dirFilter, as in WalkDirFilter; filepath.SkipDir from walkFn
+// for a file skips the rest of its directory, for a directory it is ignored.
+// If a directory cannot be read, walkFn is called for it only once, with the
+// error. A directory with many entries is walked in directory order, not
+// lexical, so not all of its entries are held in memory at once.
+func WalkPostOrder(fs afero.Fs, root string, dirFilter func(path string, fi os.FileInfo) bool, walkFn filepath.WalkFunc) error {
+	var (
+		fi  os.FileInfo
+		err error
+	)
+
+	if ls, ok := fs.(afero.Lstater); ok {
+		fi, _, err = ls.LstatIfPossible(root)
+	} else {
+		fi, err = fs.Stat(root)
+	}
+
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		err = walkPostOrder(fs, root, fi, dirFilter, walkFn)
+	}
+
+	if err == filepath.SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+func walkPostOrder(fs afero.Fs, path string, fi os.FileInfo, dirFilter func(path string, fi os.FileInfo) bool, walkFn filepath.WalkFunc) error {
+	if !fi.IsDir() {
+		return walkFn(path, fi, nil)
+	}
+
+	if dirFilter != nil && !dirFilter(path, fi) {
+		return nil
+	}
+
+	var walkErr error
+	err := walkDirEntries(fs, path, func(cfi os.FileInfo) error {
+		walkErr = walkPostOrder(fs, filepath.Join(path, cfi.Name()), cfi, dirFilter, walkFn)
+		return walkErr
+	})
+
+	if walkErr == filepath.SkipDir {
+		walkErr, err = nil, nil
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	if err != nil {
+		return skipDirToNil(walkFn(path, fi, err))
+	}
+
+	return skipDirToNil(walkFn(path, fi, nil))
+}
+
+// skipDirToNil returns nil for filepath.SkipDir, as all of a directory has
+// been walked when walkFn is called for it in post-order.
+func skipDirToNil(err error) error {
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// HashWalkFunc is the type of the function called for each file visited by
+// WalkHashed, with hash being the hex encoded digest of the file's content.
+type HashWalkFunc func(path string, fi os.FileInfo, hash string, err error) error
+
+// WalkHashed works like WalkFiles with no match func, but every regular file
+// is read through a hash.Hash created by newHash, and walkFn gets the hex
+// encoded digest, e.g. for change detection. Any error reading a file is
+// passed on to walkFn.
+func WalkHashed(fs afero.Fs, root string, newHash func() hash.Hash, walkFn HashWalkFunc) error {
+	return WalkFiles(fs, root, nil, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return walkFn(path, fi, "", err)
+		}
+
+		sum, err := hashFile(fs, path, newHash())
+
+		return walkFn(path, fi, sum, err)
+	})
+}
+
+func hashFile(fs afero.Fs, filename string, h hash.Hash) (string, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WalkStats holds the counts collected by WalkWithStats.
+type WalkStats struct {
+	Files int
+	Dirs  int
+
+	// Symbolic links are counted here, and not as files or directories,
+	// as they are not followed.
+	Symlinks int
+
+	// The sum of the sizes of the files.
+	Bytes int64
+}
+
+// WalkWithStats works like afero.Walk, but also returns the number of files,
+// directories and symbolic links visited, e.g. for a progress bar. Only the
+// entries for which walkFn returns nil are counted, so a directory skipped
+// with filepath.SkipDir and anything below it is not included.
+func WalkWithStats(fs afero.Fs, root string, walkFn filepath.WalkFunc) (WalkStats, error) {
+	var stats WalkStats
+
+	err := afero.Walk(fs, root, func(path string, fi os.FileInfo, err error) error {
+		if err := walkFn(path, fi, err); err != nil || fi == nil {
+			return err
+		}
+
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			stats.Symlinks++
+		case fi.IsDir():
+			stats.Dirs++
+		default:
+			stats.Files++
+			stats.Bytes += fi.Size()
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// The number of directory entries read at a time by ReadDirStream.
+var readDirBatchSize = 256
+
+// ReadDirStream calls fn for each entry in the directory name, in directory
+// order, reading the entries in batches to avoid holding all of them in
+// memory at once, e.g. for a static directory with tens of thousands of files.
+// Unlike afero.ReadDir, the entries are not sorted. Any error returned from fn
+// stops the read and is returned.
+func ReadDirStream(fs afero.Fs, name string, fn func(fi os.FileInfo) error) error {
+	f, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readDirStream(f, fn)
+}
+
+// walkDirEntries calls fn for each entry in the directory name. A directory
+// with fewer than readDirBatchSize entries is read in full and sorted by name,
+// as in afero.ReadDir, and closed before fn is called. A bigger directory is
+// streamed in directory order, as in ReadDirStream, to avoid holding all of
+// its entries in memory.
+func walkDirEntries(fs afero.Fs, name string, fn func(fi os.FileInfo) error) error {
+	f, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+
+	fis, err := f.Readdir(readDirBatchSize)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return err
+	}
+
+	if len(fis) < readDirBatchSize {
+		f.Close()
+		sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+		for _, fi := range fis {
+			if err := fn(fi); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	defer f.Close()
+
+	for _, fi := range fis {
+		if err := fn(fi); err != nil {
+			return err
+		}
+	}
+
+	return readDirStream(f, fn)
+}
+
+func readDirStream(f afero.File, fn func(fi os.FileInfo) error) error {
+	for {
+		fis, err := f.Readdir(readDirBatchSize)
+		for _, fi := range fis {
+			if err := fn(fi); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(fis) == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,564 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hugofs
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gohugoio/hugo/htesting/tempdir"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkVirtual(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(fs, filepath.Join("/my", "project", "blog", "a.txt"), []byte("content"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.Join("/my", "theme", "b.txt"), []byte("content"), 0755))
+
+	rfs, err := NewRootMappingFs(fs, "p", filepath.Join("/my", "project"), "t", filepath.Join("/my", "theme"))
+	assert.NoError(err)
+
+	collect := func(root string) (virtualPaths, realFilenames []string) {
+		assert.NoError(WalkVirtual(rfs, root, func(virtualPath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				return nil
+			}
+			virtualPaths = append(virtualPaths, virtualPath)
+			realFilenames = append(realFilenames, fi.(RealFilenameInfo).RealFilename())
+			return nil
+		}))
+		return
+	}
+
+	virtualPaths, realFilenames := collect("")
+	assert.Equal([]string{filepath.FromSlash("p/blog/a.txt"), filepath.FromSlash("t/b.txt")}, virtualPaths)
+	assert.Equal([]string{filepath.FromSlash("/my/project/blog/a.txt"), filepath.FromSlash("/my/theme/b.txt")}, realFilenames)
+
+	virtualPaths, _ = collect("p")
+	assert.Equal([]string{filepath.FromSlash("p/blog/a.txt")}, virtualPaths)
+}
+
+func TestWalkRoots(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{"project/data/a.toml", "project/data/sub/b.toml", "theme/data/c.toml", "theme/e.toml", "other/d.toml"} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("content"), 0755))
+	}
+
+	var filenames []string
+	assert.NoError(WalkRoots(fs, []string{
+		filepath.FromSlash("theme/data"),
+		filepath.FromSlash("project/data"),
+		// Already walked.
+		filepath.FromSlash("project/data/sub"),
+		filepath.FromSlash("project/data/"),
+		// Parent of an already walked root.
+		"theme",
+	}, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			filenames = append(filenames, filepath.ToSlash(path))
+		}
+		return nil
+	}))
+
+	assert.Equal([]string{"theme/data/c.toml", "project/data/a.toml", "project/data/sub/b.toml", "theme/e.toml"}, filenames)
+}
+
+func TestWalkDirEvents(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{"a/b/c.txt", "a/d.txt", "ab/f.txt", "e.txt", "skip/g.txt"} {
+		assert.NoError(afero.WriteFile(fs, filepath.Join("root", filepath.FromSlash(filename)), []byte("content"), 0755))
+	}
+
+	var events []string
+	record := func(event string) DirEventFunc {
+		return func(path string, fi os.FileInfo) error {
+			events = append(events, event+" "+filepath.ToSlash(path))
+			return nil
+		}
+	}
+
+	assert.NoError(WalkDirEvents(fs, "root", func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if fi.Name() == "skip" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		events = append(events, "file "+filepath.ToSlash(path))
+		return nil
+	}, record("enter"), record("leave")))
+
+	assert.Equal([]string{
+		"enter root",
+		"enter root/a",
+		"enter root/a/b",
+		"file root/a/b/c.txt",
+		"leave root/a/b",
+		"file root/a/d.txt",
+		"leave root/a",
+		"enter root/ab",
+		"file root/ab/f.txt",
+		"leave root/ab",
+		"file root/e.txt",
+		"enter root/skip",
+		"leave root/skip",
+		"leave root",
+	}, events)
+}
+
+func TestWalkFiles(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{"a.md", "b.html", "blog/c.md", "blog/d.txt", "blog/e.md/f.txt", "blog/sub/g.md"} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("content"), 0755))
+	}
+
+	collect := func(match func(fi os.FileInfo) bool) []string {
+		var filenames []string
+		assert.NoError(WalkFiles(fs, "", match, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			filenames = append(filenames, filepath.ToSlash(path))
+			return nil
+		}))
+		return filenames
+	}
+
+	assert.Equal([]string{"a.md", "blog/c.md", "blog/sub/g.md"}, collect(func(fi os.FileInfo) bool {
+		return filepath.Ext(fi.Name()) == ".md"
+	}))
+
+	assert.Equal([]string{"a.md", "b.html", "blog/c.md", "blog/d.txt", "blog/e.md/f.txt", "blog/sub/g.md"}, collect(nil))
+}
+
+type openCountingFs struct {
+	afero.Fs
+	opens int
+}
+
+func (fs *openCountingFs) Open(name string) (afero.File, error) {
+	fs.opens++
+	return fs.Fs.Open(name)
+}
+
+func TestWalkDirFilter(t *testing.T) {
+	assert := require.New(t)
+	fs := &openCountingFs{Fs: afero.NewMemMapFs()}
+
+	for _, filename := range []string{"a.js", "src/b.js", "node_modules/c/c.js", "node_modules/d/d.js", "src/node_modules/e.js"} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("content"), 0755))
+	}
+
+	var paths []string
+	assert.NoError(WalkDirFilter(fs, "", func(path string, fi os.FileInfo) bool {
+		return fi.Name() != "node_modules"
+	}, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(path))
+		return nil
+	}))
+
+	assert.Equal([]string{"", "a.js", "src", "src/b.js"}, paths)
+	// The root and src.
+	assert.Equal(2, fs.opens)
+}
+
+func BenchmarkWalkDirFilter(b *testing.B) {
+	fs := &openCountingFs{Fs: afero.NewMemMapFs()}
+	afero.WriteFile(fs, "main.js", []byte("content"), 0755)
+	for i := 0; i < 50; i++ {
+		afero.WriteFile(fs, filepath.Join("node_modules", fmt.Sprintf("module%d", i), "index.js"), []byte("content"), 0755)
+	}
+
+	walkFn := func(path string, fi os.FileInfo, err error) error {
+		return err
+	}
+
+	b.Run("WalkFiles", func(b *testing.B) {
+		fs.opens = 0
+		for i := 0; i < b.N; i++ {
+			WalkFiles(fs, "", nil, walkFn)
+		}
+		b.ReportMetric(float64(fs.opens)/float64(b.N), "opens/op")
+	})
+
+	b.Run("DirFilter", func(b *testing.B) {
+		fs.opens = 0
+		for i := 0; i < b.N; i++ {
+			WalkDirFilter(fs, "", func(path string, fi os.FileInfo) bool {
+				return fi.Name() != "node_modules"
+			}, walkFn)
+		}
+		b.ReportMetric(float64(fs.opens)/float64(b.N), "opens/op")
+	})
+}
+
+func TestWalkPostOrder(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "b/f.txt", "g.txt", "node_modules/h.js"} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte("content"), 0755))
+	}
+
+	notNodeModules := func(path string, fi os.FileInfo) bool {
+		return fi.Name() != "node_modules"
+	}
+
+	walk := func(skip string) []string {
+		var paths []string
+		assert.NoError(WalkPostOrder(fs, "", notNodeModules, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			path = filepath.ToSlash(path)
+			paths = append(paths, path)
+			if path == skip {
+				return filepath.SkipDir
+			}
+			return nil
+		}))
+		return paths
+	}
+
+	assert.Equal([]string{"a.txt", "b/c.txt", "b/d/e.txt", "b/d", "b/f.txt", "b", "g.txt", ""}, walk(""))
+
+	// Skips the rest of b, but not b itself.
+	assert.Equal([]string{"a.txt", "b/c.txt", "b", "g.txt", ""}, walk("b/c.txt"))
+	// The siblings after b are still walked.
+	assert.Equal([]string{"a.txt", "b/c.txt", "b/d/e.txt", "b/d", "b/f.txt", "b", "g.txt", ""}, walk("b"))
+	assert.Equal([]string{"a.txt", "b/c.txt", "b/d/e.txt", "b/d", "b/f.txt", "b", "g.txt", ""}, walk("b/d"))
+
+	// All directories.
+	var paths []string
+	assert.NoError(WalkPostOrder(fs, "node_modules", nil, func(path string, fi os.FileInfo, err error) error {
+		paths = append(paths, filepath.ToSlash(path))
+		return err
+	}))
+	assert.Equal([]string{"node_modules/h.js", "node_modules"}, paths)
+
+	err := WalkPostOrder(fs, "nope", nil, func(path string, fi os.FileInfo, err error) error {
+		return err
+	})
+	assert.True(os.IsNotExist(err))
+}
+
+type openFailingFs struct {
+	afero.Fs
+	name string
+}
+
+func (fs *openFailingFs) Open(name string) (afero.File, error) {
+	if name == fs.name {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return fs.Fs.Open(name)
+}
+
+func TestWalkPostOrderReadDirError(t *testing.T) {
+	assert := require.New(t)
+	mfs := afero.NewMemMapFs()
+
+	for _, filename := range []string{"a.txt", "b/c.txt", "d.txt"} {
+		assert.NoError(afero.WriteFile(mfs, filepath.FromSlash(filename), []byte("content"), 0755))
+	}
+
+	fs := &openFailingFs{Fs: mfs, name: "b"}
+
+	var paths []string
+	assert.NoError(WalkPostOrder(fs, "", nil, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			assert.True(os.IsPermission(err))
+			path += " (error)"
+		}
+		paths = append(paths, filepath.ToSlash(path))
+		return nil
+	}))
+	assert.Equal([]string{"a.txt", "b (error)", "d.txt", ""}, paths)
+
+	err := WalkPostOrder(fs, "", nil, func(path string, fi os.FileInfo, err error) error {
+		return err
+	})
+	assert.True(os.IsPermission(err))
+}
+
+func TestWalkHashed(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	assert.NoError(afero.WriteFile(fs, "a.txt", []byte("some content"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("sub/b.txt"), []byte("some content"), 0755))
+	assert.NoError(afero.WriteFile(fs, filepath.FromSlash("sub/c.txt"), []byte("some contenT"), 0755))
+
+	hashes := make(map[string]string)
+	assert.NoError(WalkHashed(fs, "", md5.New, func(path string, fi os.FileInfo, hash string, err error) error {
+		if err != nil {
+			return err
+		}
+		assert.False(fi.IsDir())
+		hashes[filepath.ToSlash(path)] = hash
+		return nil
+	}))
+
+	assert.Len(hashes, 3)
+	assert.Equal("9893532233caff98cd083a116b013c0b", hashes["a.txt"])
+	assert.Equal(hashes["a.txt"], hashes["sub/b.txt"])
+	assert.NotEqual(hashes["a.txt"], hashes["sub/c.txt"])
+}
+
+func TestWalkWithStats(t *testing.T) {
+	assert := require.New(t)
+	fs := afero.NewMemMapFs()
+
+	for _, filename := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "node_modules/f.js", "node_modules/g/h.js"} {
+		assert.NoError(afero.WriteFile(fs, filepath.FromSlash(filename), []byte(filename), 0755))
+	}
+
+	stats, err := WalkWithStats(fs, "", func(path string, fi os.FileInfo, err error) error {
+		return err
+	})
+	assert.NoError(err)
+	assert.Equal(WalkStats{Files: 5, Dirs: 5, Bytes: int64(len("a.txtb/c.txtb/d/e.txtnode_modules/f.jsnode_modules/g/h.js"))}, stats)
+
+	stats, err = WalkWithStats(fs, "", func(path string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+		return err
+	})
+	assert.NoError(err)
+	assert.Equal(WalkStats{Files: 3, Dirs: 3, Bytes: int64(len("a.txtb/c.txtb/d/e.txt"))}, stats)
+
+	_, err = WalkWithStats(fs, "nope", func(path string, fi os.FileInfo, err error) error {
+		return err
+	})
+	assert.True(os.IsNotExist(err))
+}
+
+type readdirCountingFs struct {
+	afero.Fs
+	maxEntries int
+}
+
+func (fs *readdirCountingFs) Open(name string) (afero.File, error) {
+	f, err := fs.Fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &readdirCountingFile{File: f, fs: fs}, nil
+}
+
+type readdirCountingFile struct {
+	afero.File
+	fs *readdirCountingFs
+}
+
+func (f *readdirCountingFile) Readdir(count int) ([]os.FileInfo, error) {
+	fis, err := f.File.Readdir(count)
+	if len(fis) > f.fs.maxEntries {
+		f.fs.maxEntries = len(fis)
+	}
+	return fis, err
+}
+
+func TestReadDirStream(t *testing.T) {
+	assert := require.New(t)
+	fs := &readdirCountingFs{Fs: afero.NewMemMapFs()}
+
+	numFiles := 3*readDirBatchSize + 7
+	for i := 0; i < numFiles; i++ {
+		assert.NoError(afero.WriteFile(fs, filepath.Join("static", fmt.Sprintf("file%d.txt", i)), []byte("content"), 0755))
+	}
+
+	seen := make(map[string]bool)
+	assert.NoError(ReadDirStream(fs, "static", func(fi os.FileInfo) error {
+		seen[fi.Name()] = true
+		return nil
+	}))
+
+	assert.Len(seen, numFiles)
+	assert.Equal(readDirBatchSize, fs.maxEntries)
+
+	// Stop early.
+	errStop := errors.New("stop")
+	count := 0
+	assert.Equal(errStop, ReadDirStream(fs, "static", func(fi os.FileInfo) error {
+		count++
+		if count == 10 {
+			return errStop
+		}
+		return nil
+	}))
+	assert.Equal(10, count)
+
+	assert.NoError(fs.Mkdir("empty", 0755))
+	assert.NoError(ReadDirStream(fs, "empty", func(fi os.FileInfo) error {
+		return errStop
+	}))
+
+	assert.True(os.IsNotExist(ReadDirStream(fs, "nope", func(fi os.FileInfo) error {
+		return nil
+	})))
+}
+
+func TestWalkPostOrderBigDir(t *testing.T) {
+	assert := require.New(t)
+	fs := &readdirCountingFs{Fs: afero.NewMemMapFs()}
+
+	numFiles := 3*readDirBatchSize + 7
+	for i := 0; i < numFiles; i++ {
+		assert.NoError(afero.WriteFile(fs, filepath.Join("static", fmt.Sprintf("file%d.txt", i)), []byte("content"), 0755))
+	}
+	assert.NoError(afero.WriteFile(fs, filepath.Join("static", "sub", "a.txt"), []byte("content"), 0755))
+
+	var paths []string
+	assert.NoError(WalkPostOrder(fs, "static", nil, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(path))
+		return nil
+	}))
+
+	assert.Len(paths, numFiles+3)
+	assert.Equal("static", paths[len(paths)-1])
+	assert.Equal(readDirBatchSize, fs.maxEntries)
+}
+
+func BenchmarkReadDirStream(b *testing.B) {
+	// MemMapFs sorts the entire directory on every Readdir, so use the OS.
+	dir, clean, err := tempdir.Create(Os, "hugofs-readdir")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer clean()
+
+	fs := afero.NewBasePathFs(Os, dir)
+	if err := fs.Mkdir("static", 0755); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 10000; i++ {
+		afero.WriteFile(fs, filepath.Join("static", fmt.Sprintf("file%d.txt", i)), []byte("content"), 0755)
+	}
+
+	b.Run("ReadDir", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fis, _ := afero.ReadDir(fs, "static")
+			for range fis {
+			}
+		}
+	})
+
+	b.Run("ReadDirStream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ReadDirStream(fs, "static", func(fi os.FileInfo) error {
+				return nil
+			})
+		}
+	})
+
+	b.Run("WalkPostOrder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			WalkPostOrder(fs, "static", nil, func(path string, fi os.FileInfo, err error) error {
+				return err
+			})
+		}
+	})
+}
+
+func TestWalkFilesBrokenSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestWalkFilesBrokenSymlink as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	d, clean, err := tempdir.Create(Os, "hugo-walk-broken-symlink")
+	assert.NoError(err)
+	defer clean()
+
+	assert.NoError(os.Mkdir(filepath.Join(d, "static"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(d, "static", "a.txt"), []byte("some content"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(d, "static", "c.txt"), []byte("some content"), 0755))
+	assert.NoError(os.Symlink(filepath.Join(d, "nope.txt"), filepath.Join(d, "static", "b.txt")))
+
+	rfs, err := NewRootMappingFs(Os, "static", filepath.Join(d, "static"))
+	assert.NoError(err)
+	rfs.ResolveSymlinks = true
+
+	for _, fs := range []afero.Fs{afero.NewBasePathFs(Os, d), rfs} {
+		var regular []string
+		assert.NoError(WalkFiles(fs, "static", nil, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.Mode().IsRegular() {
+				regular = append(regular, fi.Name())
+			}
+			return nil
+		}))
+
+		// The broken symlink is walked as a symlink, not followed.
+		assert.Equal([]string{"a.txt", "c.txt"}, regular)
+	}
+
+	stats, err := WalkWithStats(afero.NewBasePathFs(Os, d), "static", func(path string, fi os.FileInfo, err error) error {
+		return err
+	})
+	assert.NoError(err)
+	assert.Equal(WalkStats{Files: 2, Dirs: 1, Symlinks: 1, Bytes: int64(2 * len("some content"))}, stats)
+
+	for _, test := range []struct {
+		name   string
+		broken bool
+	}{
+		{"a.txt", false},
+		{"b.txt", true},
+	} {
+		filename := filepath.Join(d, "static", test.name)
+		fi, err := os.Lstat(filename)
+		assert.NoError(err)
+		assert.Equal(test.broken, IsBrokenSymlink(Os, filename, fi), test.name)
+	}
+	assert.False(IsBrokenSymlink(Os, filepath.Join(d, "static"), nil))
+}
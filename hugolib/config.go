@@ -629,6 +629,7 @@ func loadDefaultSettingsFor(v *viper.Viper) error {
 	v.SetDefault("defaultContentLanguageInSubdir", false)
 	v.SetDefault("enableMissingTranslationPlaceholders", false)
 	v.SetDefault("enableGitInfo", false)
+	v.SetDefault("skipBrokenSymlinks", false)
 	v.SetDefault("ignoreFiles", make([]string, 0))
 	v.SetDefault("disableAliases", false)
 	v.SetDefault("debug", false)
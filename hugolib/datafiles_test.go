@@ -215,12 +215,12 @@ func TestDataDirMultipleSourcesCommingled(t *testing.T) {
 	// Per handleDataFile() comment:
 	// 1. A theme uses the same key; the main data folder wins
 	// 2. A sub folder uses the same key: the sub folder wins
+	// The theme's a.json and a/b1.json are hidden by the project's.
 	expected :=
 		map[string]interface{}{
 			"a": map[string]interface{}{
 				"b1": map[string]interface{}{
 					"c1": "data/a/b1",
-					"c2": "mytheme/data/a/b1",
 				},
 				"b2": "data/a",
 				"b3": []interface{}{"x", "y", "z"},
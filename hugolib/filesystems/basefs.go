@@ -16,10 +16,15 @@
 package filesystems
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gohugoio/hugo/config"
 
@@ -27,6 +32,7 @@ import (
 
 	"fmt"
 
+	"github.com/gobwas/glob"
 	"github.com/gohugoio/hugo/hugolib/paths"
 	"github.com/gohugoio/hugo/langs"
 	"github.com/spf13/afero"
@@ -51,125 +57,1280 @@ type BaseFs struct {
 	// This usually maps to /my-project/public.
 	PublishFs afero.Fs
 
+	// The absolute filenames of the publish and working dirs.
+	absPublishDir string
+	workingDir    string
+
+	// Set if PublishFs is on the OS filesystem, where the publish dir may be a
+	// symbolic link.
+	publishOnOs bool
+
 	themeFs afero.Fs
 
-	// TODO(bep) improve the "theme interaction"
-	AbsThemeDirs []string
-}
+	// TODO(bep) improve the "theme interaction"
+	AbsThemeDirs []string
+
+	// The directories mounted into the filesystems above.
+	mounts []mount
+
+	// If set, only these themes will be added to the filesystems.
+	onlyThemes []string
+
+	// If set, only these components will be built.
+	components map[string]bool
+
+	// Directories left out of the filesystems above as they did not exist.
+	missingDirs []string
+
+	// If set, the resources filesystem is read-only and no missing
+	// directories are created.
+	readOnly bool
+}
+
+// mount describes a directory in the source filesystem mounted into one of
+// the component filesystems.
+type mount struct {
+	// The component, e.g. "content", "layouts" or "static".
+	component string
+
+	// The path in the component filesystem this directory is available from.
+	// This is blank for the overlay filesystems, where all directories are
+	// merged into the root.
+	target string
+
+	// The absolute filename of the mounted directory.
+	source string
+
+	// The name of the theme providing this directory. Blank for the project.
+	theme string
+
+	// The language of the files in this directory, if set.
+	lang string
+
+	// Whether changes in this directory are watched in server mode.
+	watch bool
+}
+
+// The components watched for changes in server mode, i.e. all but archetypes
+// and resources.
+var watchedComponents = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, component := range metaComponents {
+		m[component] = component != "archetypes" && component != "resources"
+	}
+	return m
+}()
+
+func newMount(component, target, source, theme, lang string) mount {
+	return mount{
+		component: component,
+		target:    target,
+		source:    source,
+		theme:     theme,
+		lang:      lang,
+		watch:     watchedComponents[component],
+	}
+}
+
+func (m mount) origin() string {
+	if m.theme == "" {
+		return "project"
+	}
+	return m.theme
+}
+
+// DescribeMounts returns a human readable description of each mounted directory,
+// one per line, e.g. "layouts: layouts <- /my/project/layouts (project, watch)".
+// This is useful for logging.
+func (b *BaseFs) DescribeMounts() []string {
+	lines := make([]string, len(b.mounts))
+	for i, m := range b.mounts {
+		attrs := []string{m.origin()}
+		if m.lang != "" {
+			attrs = append(attrs, "lang="+m.lang)
+		}
+		if m.watch {
+			attrs = append(attrs, "watch")
+		}
+		lines[i] = fmt.Sprintf("%s: %s <- %s (%s)", m.component, filepath.Join(m.component, m.target), filepath.Clean(m.source), strings.Join(attrs, ", "))
+	}
+	return lines
+}
+
+// mountEntry is the JSON representation of a mount in MountTableJSON.
+type mountEntry struct {
+	Component string `json:"component"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Lang      string `json:"lang,omitempty"`
+	Watch     bool   `json:"watch"`
+	Origin    string `json:"origin"`
+}
+
+// MountTableJSON returns a JSON snapshot of every mounted directory in the
+// order they were mounted, i.e. with the mounts taking precedence first within
+// each component. This is useful to debug which file wins when the same
+// file exists in both the project and a theme.
+func (b *BaseFs) MountTableJSON() ([]byte, error) {
+	entries := make([]mountEntry, len(b.mounts))
+	for i, m := range b.mounts {
+		entries[i] = mountEntry{
+			Component: m.component,
+			Source:    filepath.Clean(m.source),
+			Target:    filepath.Join(m.component, m.target),
+			Lang:      m.lang,
+			Watch:     m.watch,
+			Origin:    m.origin(),
+		}
+	}
+	return json.MarshalIndent(entries, "", "  ")
+}
+
+// ContentMount is a directory to mount into the content filesystem, see
+// WithExtraMounts.
+type ContentMount struct {
+	// The absolute filename of the directory.
+	Dir string
+
+	// The language of the files in the directory.
+	Lang string
+}
+
+// WithExtraMounts returns a copy of b where the given directories in source,
+// e.g. a MemMapFs with generated content, are mounted on top of the content
+// filesystem. If more than one of them has the same file, the first one wins.
+// The other filesystems are shared with b, which is left unchanged.
+func (b *BaseFs) WithExtraMounts(mounts []ContentMount, source afero.Fs) (*BaseFs, error) {
+	var (
+		fs            = b.Content.Fs
+		contentMounts []mount
+		dirnames      []string
+	)
+
+	for _, m := range mounts {
+		if !b.languages[m.Lang] {
+			return nil, fmt.Errorf("invalid language %q for content dir %q", m.Lang, m.Dir)
+		}
+		if !filepath.IsAbs(m.Dir) {
+			return nil, fmt.Errorf("content dir %q must be absolute", m.Dir)
+		}
+
+		absDir := filepath.Clean(m.Dir)
+		contentMounts = append(contentMounts, newMount("content", "", absDir, "", m.Lang))
+		dirnames = append(dirnames, absDir)
+	}
+
+	for i := len(mounts) - 1; i >= 0; i-- {
+		m := mounts[i]
+		overlay := hugofs.NewLanguageFs(m.Lang, b.languages, afero.NewBasePathFs(source, filepath.Clean(m.Dir)))
+		fs = hugofs.NewLanguageCompositeFs(fs, overlay)
+	}
+
+	sourceFilesystems := *b.SourceFilesystems
+	sourceFilesystems.Content = &SourceFilesystem{
+		SourceFs: newDirRoutingFs(b.Content.SourceFs, source, dirnames),
+		Fs:       fs,
+		Dirnames: append(dirnames, b.Content.Dirnames...),
+	}
+	sourceFilesystems.contentMounts = append(append([]mount(nil), contentMounts...), b.contentMounts...)
+
+	bb := *b
+	bb.SourceFilesystems = &sourceFilesystems
+	bb.mounts = append(append([]mount(nil), contentMounts...), b.mounts...)
+
+	return &bb, nil
+}
+
+// dirRoutingFs reads any file below one of dirs from fs, anything else from
+// the base filesystem. This allows directories in another filesystem to be
+// added to a SourceFilesystem's Dirnames. Writes always go to the base.
+type dirRoutingFs struct {
+	afero.Fs
+	fs   afero.Fs
+	dirs []string
+}
+
+func newDirRoutingFs(base, fs afero.Fs, dirs []string) afero.Fs {
+	if len(dirs) == 0 {
+		return base
+	}
+	return &dirRoutingFs{Fs: base, fs: fs, dirs: dirs}
+}
+
+func (fs *dirRoutingFs) route(name string) afero.Fs {
+	name = filepath.Clean(name)
+	for _, dir := range fs.dirs {
+		if _, ok := relToDir(dir, name); ok {
+			return fs.fs
+		}
+	}
+	return fs.Fs
+}
+
+func (fs *dirRoutingFs) Stat(name string) (os.FileInfo, error) {
+	return fs.route(name).Stat(name)
+}
+
+func (fs *dirRoutingFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	rfs := fs.route(name)
+	if ls, ok := rfs.(afero.Lstater); ok {
+		return ls.LstatIfPossible(name)
+	}
+	fi, err := rfs.Stat(name)
+	return fi, false, err
+}
+
+func (fs *dirRoutingFs) Open(name string) (afero.File, error) {
+	return fs.route(name).Open(name)
+}
+
+func (fs *dirRoutingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0 {
+		return fs.Fs.OpenFile(name, flag, perm)
+	}
+	return fs.route(name).OpenFile(name, flag, perm)
+}
+
+func (fs *dirRoutingFs) Name() string {
+	return "dirRoutingFs"
+}
+
+// DiffContent compares the content filesystems of a and b, e.g. built from
+// two different configurations, and returns the sorted paths, relative to the
+// content root, of the files added in b, removed in b and shadowed in b, i.e.
+// where the same path in the same language now is another file.
+// A path in more than one language is compared per language. Files that
+// cannot be read are skipped.
+func DiffContent(a, b *BaseFs) (added, removed, shadowed []string) {
+	fa, fb := contentFilenames(a), contentFilenames(b)
+
+	for key, filename := range fb {
+		if existing, found := fa[key]; !found {
+			added = append(added, key.path)
+		} else if existing != filename {
+			shadowed = append(shadowed, key.path)
+		}
+	}
+
+	for key := range fa {
+		if _, found := fb[key]; !found {
+			removed = append(removed, key.path)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(shadowed)
+
+	return
+}
+
+type contentKey struct {
+	lang string
+	path string
+}
+
+// contentFilenames maps every file in the content filesystem of b to its
+// real filename.
+func contentFilenames(b *BaseFs) map[contentKey]string {
+	filenames := make(map[contentKey]string)
+
+	files, _ := b.ListContent()
+	for _, f := range files {
+		filenames[contentKey{lang: f.Lang, path: f.Path}] = f.Filename
+	}
+
+	return filenames
+}
+
+// WatchDirs returns the absolute filenames of the mounted directories that
+// should be watched for changes in server mode, including the static dirs.
+func (b *BaseFs) WatchDirs() []string {
+	var (
+		dirs []string
+		seen = make(map[string]bool)
+	)
+
+	for _, m := range b.mounts {
+		dir := filepath.Clean(m.source)
+		if !m.watch || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
+
+// MissingDirs returns the sorted absolute filenames of the project and theme
+// directories that were left out of the filesystems because they did not exist
+// when created, e.g. a project's i18n dir. Creating one of these will not be
+// picked up until the BaseFs is rebuilt, so a file watcher may want to watch
+// their parent directories.
+func (b *BaseFs) MissingDirs() []string {
+	var (
+		dirs []string
+		seen = make(map[string]bool)
+	)
+
+	for _, dir := range b.missingDirs {
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// WatchFilenames works as WatchDirs, but the directories are sorted, ready
+// to be added to a file watcher.
+func (b *BaseFs) WatchFilenames() []string {
+	dirs := b.WatchDirs()
+	sort.Strings(dirs)
+	return dirs
+}
+
+// WatchConflicts returns a description of each directory that is mounted both
+// as a watched and an unwatched component, e.g. when the same directory is used
+// for both layouts and archetypes. Changes in such a directory will trigger
+// a rebuild of the watched components only.
+func (b *BaseFs) WatchConflicts() []string {
+	var (
+		dirs      []string
+		watched   = make(map[string][]string)
+		unwatched = make(map[string][]string)
+	)
+
+	for _, m := range b.mounts {
+		dir := filepath.Clean(m.source)
+		if _, found := watched[dir]; !found {
+			if _, found := unwatched[dir]; !found {
+				dirs = append(dirs, dir)
+			}
+		}
+		desc := fmt.Sprintf("%s (%s)", m.component, m.origin())
+		if m.watch {
+			watched[dir] = append(watched[dir], desc)
+		} else {
+			unwatched[dir] = append(unwatched[dir], desc)
+		}
+	}
+
+	var conflicts []string
+	for _, dir := range dirs {
+		if len(watched[dir]) > 0 && len(unwatched[dir]) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%s: watched as %s, not watched as %s", dir, strings.Join(watched[dir], ", "), strings.Join(unwatched[dir], ", ")))
+		}
+	}
+
+	return conflicts
+}
+
+// SourceFanout returns the source directories that are mounted into more than
+// one target, e.g. the same directory used as both static and assets. Each
+// directory maps to its sorted targets. Such setups are usually a mistake, as
+// the files will be processed once for every target.
+func (b *BaseFs) SourceFanout() map[string][]string {
+	targets := make(map[string][]string)
+
+	for _, m := range b.mounts {
+		dir := filepath.Clean(m.source)
+		target := filepath.Join(m.component, m.target)
+		found := false
+		for _, t := range targets[dir] {
+			if t == target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			targets[dir] = append(targets[dir], target)
+		}
+	}
+
+	fanout := make(map[string][]string)
+	for dir, t := range targets {
+		if len(t) > 1 {
+			sort.Strings(t)
+			fanout[dir] = t
+		}
+	}
+
+	return fanout
+}
+
+// Origin returns the name of the theme the given file in the named component,
+// e.g. "layouts", comes from, or an empty string if it comes from the project.
+// The filename is relative to the component filesystem. If the file exists in
+// both the project and a theme, the origin of the file that wins is returned.
+func (b *BaseFs) Origin(component, filename string) (string, error) {
+	sfs, found := b.ForComponent(component)
+	if !found {
+		return "", fmt.Errorf("unknown component %q", component)
+	}
+
+	fi, err := sfs.Fs.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+
+	rfi, ok := fi.(hugofs.RealFilenameInfo)
+	if !ok {
+		return "", fmt.Errorf("no real filename found for %q", filename)
+	}
+	realFilename := rfi.RealFilename()
+
+	var match *mount
+	for i, m := range b.mounts {
+		dir := filepath.Clean(m.source) + filePathSeparator
+		if m.component != component || !strings.HasPrefix(realFilename, dir) {
+			continue
+		}
+		if match == nil || len(m.source) > len(match.source) {
+			match = &b.mounts[i]
+		}
+	}
+
+	if match == nil {
+		return "", fmt.Errorf("no mount found for %q", realFilename)
+	}
+
+	return match.theme, nil
+}
+
+// MergePublish copies the given paths, relative to the root of staging, into
+// PublishFs, overwriting any existing file. Directories are copied with all
+// their files. This allows a partial rebuild to be published to staging
+// first.
+func (b *BaseFs) MergePublish(staging afero.Fs, paths []string) error {
+	for _, path := range paths {
+		err := afero.Walk(staging, path, func(filename string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				return b.PublishFs.MkdirAll(filename, 0777)
+			}
+			return copyFile(staging, b.PublishFs, filename)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to publish %q: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// CleanPublish removes everything in PublishFs except the given paths
+// relative to the publish dir, e.g. ".git" or "CNAME". It refuses to do so if
+// the publish dir is not set, is the filesystem root, or is the working dir
+// or one of its parents, as that would remove the project itself.
+// On the OS filesystem, the publish dir must not be a symbolic link to
+// somewhere else, and the checks above are done on the resolved paths.
+func (b *BaseFs) CleanPublish(keep []string) error {
+	publishDir := filepath.Clean(b.absPublishDir)
+	workingDir := filepath.Clean(b.workingDir)
+
+	if b.publishOnOs && b.absPublishDir != "" {
+		resolved, err := filepath.EvalSymlinks(publishDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		parent, err := filepath.EvalSymlinks(filepath.Dir(publishDir))
+		if err != nil {
+			return err
+		}
+		if resolved != filepath.Join(parent, filepath.Base(publishDir)) {
+			return fmt.Errorf("refusing to clean publish dir %q: it resolves to %q", publishDir, resolved)
+		}
+		publishDir = resolved
+
+		if b.workingDir != "" {
+			if resolved, err := filepath.EvalSymlinks(workingDir); err == nil {
+				workingDir = resolved
+			}
+		}
+	}
+
+	if b.absPublishDir == "" || publishDir == filePathSeparator || publishDir == filepath.VolumeName(publishDir)+filePathSeparator {
+		return fmt.Errorf("refusing to clean publish dir %q", b.absPublishDir)
+	}
+
+	if b.workingDir != "" && (publishDir == workingDir || strings.HasPrefix(workingDir, publishDir+filePathSeparator)) {
+		return fmt.Errorf("refusing to clean publish dir %q: the working dir %q is inside it", publishDir, workingDir)
+	}
+
+	keepSet := make(map[string]bool)
+	for _, k := range keep {
+		keepSet[filepath.Clean(strings.TrimPrefix(k, filePathSeparator))] = true
+	}
+
+	return cleanDir(b.PublishFs, "", keepSet)
+}
+
+// cleanDir removes everything in dir that is not in keep or has a file in
+// keep below it.
+func cleanDir(fs afero.Fs, dir string, keep map[string]bool) error {
+	fis, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, fi := range fis {
+		filename := filepath.Join(dir, fi.Name())
+		if keep[filename] {
+			continue
+		}
+
+		keepBelow := false
+		if fi.IsDir() {
+			for k := range keep {
+				if strings.HasPrefix(k, filename+filePathSeparator) {
+					keepBelow = true
+					break
+				}
+			}
+		}
+
+		if keepBelow {
+			if err := cleanDir(fs, filename, keep); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fs.RemoveAll(filename); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(from, to afero.Fs, filename string) error {
+	src, err := from.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := to.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+
+	dst, err := to.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// RelContentDir tries to create a path relative to the content root from
+// the given filename. The return value is the path and language code.
+func (b *BaseFs) RelContentDir(filename string) string {
+	for _, dirname := range b.SourceFilesystems.Content.Dirnames {
+		if rel, ok := relToDir(dirname, filename); ok {
+			return strings.TrimPrefix(rel, filePathSeparator)
+		}
+	}
+	// Either not a content dir or already relative.
+	return filename
+}
+
+// SourceFilesystems contains the different source file systems. These can be
+// composite file systems (theme and project etc.), and they have all root
+// set to the source type the provides: data, i18n, static, layouts.
+type SourceFilesystems struct {
+	Content    *SourceFilesystem
+	Data       *SourceFilesystem
+	I18n       *SourceFilesystem
+	Layouts    *SourceFilesystem
+	Archetypes *SourceFilesystem
+	Assets     *SourceFilesystem
+	Resources  *SourceFilesystem
+
+	// This is a unified read-only view of the project's and themes' workdir.
+	Work *SourceFilesystem
+
+	// When in multihost we have one static filesystem per language. The sync
+	// static files is currently done outside of the Hugo build (where there is
+	// a concept of a site per language).
+	// When in non-multihost mode there will be one entry in this map with a blank key.
+	Static map[string]*SourceFilesystem
+
+	// Used to determine the language of a content file.
+	contentMounts          []mount
+	languages              map[string]bool
+	defaultContentLanguage string
+}
+
+// A SourceFilesystem holds the filesystem for a given source type in Hugo (data,
+// i18n, layouts, static) and additional metadata to be able to use that filesystem
+// in server mode.
+type SourceFilesystem struct {
+	// This is a virtual composite filesystem. It expects path relative to a context.
+	Fs afero.Fs
+
+	// This is the base source filesystem. In real Hugo, this will be the OS filesystem.
+	// Use this if you need to resolve items in Dirnames below.
+	SourceFs afero.Fs
+
+	// Dirnames is absolute filenames to the directories in this filesystem.
+	Dirnames []string
+
+	// When syncing a source folder to the target (e.g. /public), this may
+	// be set to publish into a subfolder. This is used for static syncing
+	// in multihost mode.
+	PublishFolder string
+
+	// Whether Fs accepts writes.
+	writable bool
+
+	// Dirnames with any symbolic links resolved, created on first use.
+	resolvedDirnamesInit sync.Once
+	resolvedDirnames     []string
+
+	// The resolved directories of the filenames given to Contains, as file
+	// events usually come in bursts in the same directory.
+	resolvedDirsMu sync.Mutex
+	resolvedDirs   map[string]string
+}
+
+// ForComponent returns the filesystem for the given component, i.e. one of
+// "content", "data", "i18n", "layouts", "archetypes", "assets", "resources"
+// and "static". For static, this is the filesystem of the default content
+// language in multihost mode.
+func (s SourceFilesystems) ForComponent(name string) (*SourceFilesystem, bool) {
+	var fs *SourceFilesystem
+
+	switch name {
+	case "content":
+		fs = s.Content
+	case "data":
+		fs = s.Data
+	case "i18n":
+		fs = s.I18n
+	case "layouts":
+		fs = s.Layouts
+	case "archetypes":
+		fs = s.Archetypes
+	case "assets":
+		fs = s.Assets
+	case "resources":
+		fs = s.Resources
+	case "static":
+		fs = s.Static[""]
+		if fs == nil {
+			fs = s.Static[s.defaultContentLanguage]
+		}
+	}
+
+	return fs, fs != nil
+}
+
+// ContentStaticAssetFs will create a new composite filesystem from the content,
+// static, and asset filesystems. The site language is needed to pick the correct static filesystem.
+// The order is content, static and then assets.
+// TODO(bep) check usage
+func (s SourceFilesystems) ContentStaticAssetFs(lang string) afero.Fs {
+	staticFs := s.StaticFs(lang)
+
+	base := afero.NewCopyOnWriteFs(s.Assets.Fs, staticFs)
+	return afero.NewCopyOnWriteFs(base, s.Content.Fs)
+
+}
+
+// StaticFs returns the static filesystem for the given language.
+// This can be a composite filesystem.
+func (s SourceFilesystems) StaticFs(lang string) afero.Fs {
+	var staticFs afero.Fs = hugofs.NoOpFs
+
+	if fs, ok := s.Static[lang]; ok {
+		staticFs = fs.Fs
+	} else if fs, ok := s.Static[""]; ok {
+		staticFs = fs.Fs
+	}
+
+	return staticFs
+}
+
+// StaticPublishPath returns the path relative to the publish dir where the
+// given static file, relative to the static root, is published for the given
+// language, e.g. "/sv/css/main.css" in multihost mode and "/css/main.css"
+// otherwise.
+func (s SourceFilesystems) StaticPublishPath(lang, rel string) string {
+	var publishFolder string
+
+	if fs, ok := s.Static[lang]; ok {
+		publishFolder = fs.PublishFolder
+	} else if fs, ok := s.Static[""]; ok {
+		publishFolder = fs.PublishFolder
+	}
+
+	return filepath.Join(filePathSeparator, publishFolder, rel)
+}
+
+// StatResource looks for a resource in these filesystems in order: static, assets and finally content.
+// If found in any of them, it returns FileInfo and the relevant filesystem.
+// Any non os.IsNotExist error will be returned.
+// An os.IsNotExist error wil be returned only if all filesystems return such an error.
+// Note that if we only wanted to find the file, we could create a composite Afero fs,
+// but we also need to know which filesystem root it lives in.
+func (s SourceFilesystems) StatResource(lang, filename string) (fi os.FileInfo, fs afero.Fs, err error) {
+	for _, fsToCheck := range []afero.Fs{s.StaticFs(lang), s.Assets.Fs, s.Content.Fs} {
+		fs = fsToCheck
+		fi, err = fs.Stat(filename)
+		if err == nil || !os.IsNotExist(err) {
+			return
+		}
+	}
+	// Not found.
+	return
+}
+
+// ResolveWithFallback looks for a file in static, assets and content, in that
+// order, for each of the given languages in turn, e.g. the current language
+// followed by the default content language. A content file is only found for
+// the languages it is in, so a file in the content dir of the default
+// language, or with its language in the filename, is not found for another
+// language. The static filesystem used is the one for the language.
+// If found, it returns the FileMeta, with Lang set to the language
+// it was found for, and the filesystem the file was found in. For content, this
+// is a view of the content filesystem for that language, so opening Path opens
+// Filename.
+// An os.IsNotExist error is returned if the file was not found.
+func (s SourceFilesystems) ResolveWithFallback(languages []string, filename string) (FileMeta, afero.Fs, error) {
+	filename = strings.TrimPrefix(filepath.Clean(filename), filePathSeparator)
+
+	for _, lang := range languages {
+		for _, component := range []string{"static", "assets", "content"} {
+			var (
+				fs  afero.Fs
+				fi  os.FileInfo
+				err error
+			)
+
+			switch component {
+			case "static":
+				fs = s.StaticFs(lang)
+				fi, err = fs.Stat(filename)
+			case "assets":
+				fs = s.Assets.Fs
+				fi, err = fs.Stat(filename)
+			case "content":
+				fs = &contentLangFs{Fs: s.ContentFsForLang(lang), s: s, lang: lang}
+				fi, err = fs.Stat(filename)
+			}
+
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return FileMeta{}, nil, err
+			}
+
+			if fi.IsDir() {
+				continue
+			}
+
+			meta := FileMeta{Component: component, Lang: lang, Path: filename, Filename: filename, ModTime: fi.ModTime()}
+
+			if fp, ok := fi.(hugofs.FilePather); ok {
+				meta.Filename = fp.Filename()
+			} else if rfi, ok := fi.(hugofs.RealFilenameInfo); ok {
+				meta.Filename = rfi.RealFilename()
+			}
+
+			return meta, fs, nil
+		}
+	}
+
+	return FileMeta{}, nil, &os.PathError{Op: "stat", Path: filename, Err: os.ErrNotExist}
+}
+
+// statContent returns the content file with the given filename, relative to the
+// content root, in the given language.
+func (s SourceFilesystems) statContent(lang, filename string) (os.FileInfo, error) {
+	dirname, name := filepath.Split(filename)
+
+	fis, err := afero.ReadDir(s.ContentFsForLang(lang), dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range fis {
+		if lfi, ok := fi.(*hugofs.LanguageFileInfo); ok && !lfi.IsDir() && lfi.RealName() == name {
+			return lfi, nil
+		}
+	}
+
+	return nil, &os.PathError{Op: "stat", Path: filename, Err: os.ErrNotExist}
+}
+
+// contentLangFs is a read-only view of the content filesystem for one
+// language, where a file is opened by its path relative to the content root,
+// e.g. "blog/post.md", and not by its language marked name.
+type contentLangFs struct {
+	afero.Fs
+	s    SourceFilesystems
+	lang string
+}
+
+func (fs *contentLangFs) Stat(name string) (os.FileInfo, error) {
+	fi, err := fs.s.statContent(fs.lang, name)
+	if err == nil || !os.IsNotExist(err) {
+		return fi, err
+	}
+
+	fi, err = fs.Fs.Stat(name)
+	if err == nil && !fi.IsDir() {
+		// A file in another language.
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return fi, err
+}
+
+func (fs *contentLangFs) Open(name string) (afero.File, error) {
+	fi, err := fs.s.statContent(fs.lang, name)
+	if err == nil {
+		return fs.s.Content.Fs.Open(filepath.Join(filepath.Dir(filepath.Clean(name)), fi.Name()))
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fi, err = fs.Fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	return fs.Fs.Open(name)
+}
+
+func (fs *contentLangFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag != os.O_RDONLY {
+		// The content filesystem is read-only.
+		return fs.Fs.OpenFile(name, flag, perm)
+	}
+	return fs.Open(name)
+}
+
+func (fs *contentLangFs) Name() string {
+	return "contentLangFs"
+}
+
+// ResourceFileInfo is a file found in one of the resource filesystems.
+type ResourceFileInfo struct {
+	os.FileInfo
+
+	// Path is the slash separated path relative to the filesystem root,
+	// e.g. "images/sunset.png".
+	Path string
+
+	// Fs is the filesystem the file was found in.
+	Fs afero.Fs
+}
+
+// GlobResources finds all files in static, assets and content (in that order)
+// with a path relative to the filesystem root matching the given Glob pattern,
+// e.g. "images/**/*.png".
+// If the same relative path exists in more than one of the filesystems, only
+// the first one found is returned, using the same order as StatResource.
+// See https://github.com/gobwas/glob for the full rules set.
+func (s SourceFilesystems) GlobResources(lang, pattern string) ([]ResourceFileInfo, error) {
+	g, err := glob.Compile(pattern, '/')
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ResourceFileInfo
+	seen := make(map[string]bool)
+
+	for _, fs := range []afero.Fs{s.StaticFs(lang), s.Assets.Fs, s.Content.Fs} {
+		err := afero.Walk(fs, "", func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			if fp, ok := info.(hugofs.FilePather); ok {
+				// The language filesystem marks the file names with the language.
+				path = fp.Path()
+			}
+
+			path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+
+			if seen[path] || !g.Match(path) {
+				return nil
+			}
+			seen[path] = true
+
+			result = append(result, ResourceFileInfo{FileInfo: info, Path: path, Fs: fs})
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// LanguagesFor returns the sorted languages with a content file for the given
+// path without any language or extension, e.g. "blog/post" for
+// "blog/post.sv.md", relative to the content root.
+func (s SourceFilesystems) LanguagesFor(virtualPath string) ([]string, error) {
+	dirname, name := filepath.Split(filepath.Clean(virtualPath))
+
+	dir, err := s.Content.Fs.Open(dirname)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var languages []string
+	seen := make(map[string]bool)
+
+	for _, fi := range fis {
+		lfi, ok := fi.(*hugofs.LanguageFileInfo)
+		if !ok || lfi.IsDir() || lfi.TranslationBaseName() != name || seen[lfi.Lang()] {
+			continue
+		}
+		seen[lfi.Lang()] = true
+		languages = append(languages, lfi.Lang())
+	}
+
+	sort.Strings(languages)
+
+	return languages, nil
+}
+
+// DataFs returns the data filesystem for the given language. A file with the
+// language in its name, e.g. "menu.sv.yaml", is presented as "menu.yaml" and
+// wins over any file already named so. Files for the other languages are hidden.
+func (s SourceFilesystems) DataFs(lang string) afero.Fs {
+	return hugofs.NewLanguageSuffixFs(lang, s.languages, s.Data.Fs)
+}
+
+// Archetype resolves the archetype for the given content kind and extension
+// (e.g. "post" and ".md") across the project and theme archetype dirs. If not
+// found, it falls back to "default" with the given extension, and then to a
+// "default" archetype directory. It returns the path relative to the
+// archetypes filesystem and its os.FileInfo, which is a RealFilenameInfo.
+func (s SourceFilesystems) Archetype(kind, ext string) (string, os.FileInfo, bool) {
+	var pathsToCheck []string
+
+	if kind != "" {
+		pathsToCheck = append(pathsToCheck, kind+ext)
+	}
+	pathsToCheck = append(pathsToCheck, "default"+ext, "default")
+
+	for _, p := range pathsToCheck {
+		fi, err := s.Archetypes.Fs.Stat(p)
+		if err == nil {
+			return p, fi, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// IsStatic returns true if the given filename is a member of one of the static
+// filesystems.
+func (s SourceFilesystems) IsStatic(filename string) bool {
+	for _, staticFs := range s.Static {
+		if staticFs.Contains(filename) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsContent returns true if the given filename is a member of the content filesystem.
+func (s SourceFilesystems) IsContent(filename string) bool {
+	return s.Content.Contains(filename)
+}
+
+// ContentLang returns the language of the given content file, which is either
+// an absolute filename or relative to the content root.
+// A valid language in the filename (e.g. "post.sv.md") wins over the language
+// of the content dir the file lives in. If neither can be determined, the
+// default content language is returned.
+func (s SourceFilesystems) ContentLang(filename string) string {
+	base := filepath.Base(filename)
+	baseNoExt := strings.TrimSuffix(base, filepath.Ext(base))
+	fileLang := strings.TrimPrefix(filepath.Ext(baseNoExt), ".")
+	if s.languages[fileLang] {
+		return fileLang
+	}
+
+	for _, m := range s.contentMounts {
+		if strings.HasPrefix(filename, filepath.Clean(m.source)+filePathSeparator) {
+			return m.lang
+		}
+	}
+
+	return s.defaultContentLanguage
+}
+
+// ContentFsForLang returns a view of the content filesystem where directory
+// listings only include the content files in the given language, including
+// files without a language in their filename in a content dir for that
+// language. Directories are always included. Stat and Open of a file in
+// another language return an os.IsNotExist error.
+func (s SourceFilesystems) ContentFsForLang(lang string) afero.Fs {
+	return hugofs.NewLanguageFallbackFs(s.Content.Fs, []string{lang})
+}
+
+// FileMeta describes a file in one of the source filesystems.
+type FileMeta struct {
+	// The component, e.g. "content" or "layouts".
+	Component string
+
+	// The language of the file. For content, this is the language returned
+	// by ContentLang, else the language given to MetaFor.
+	Lang string
+
+	// The path relative to the component root, e.g. "blog/post.sv.md".
+	Path string
+
+	// The absolute filename.
+	Filename string
+
+	// The modification time of the file when the FileMeta was created.
+	ModTime time.Time
+}
+
+// CacheKey returns a string identifying the file described by m, suitable
+// as a key in caches. It is built from the component, the language, the
+// filename and the modification time; the path is left out, as it is given
+// by the others. The strings are quoted, so no two files get the same key.
+func (m FileMeta) CacheKey() string {
+	return fmt.Sprintf("%q|%q|%q|%d", m.Component, m.Lang, m.Filename, m.ModTime.UnixNano())
+}
+
+// Equal reports whether m and other describe the same file, i.e. whether they
+// have the same CacheKey.
+func (m FileMeta) Equal(other FileMeta) bool {
+	return m.CacheKey() == other.CacheKey()
+}
+
+var metaComponents = []string{"content", "data", "i18n", "layouts", "archetypes", "assets", "resources", "static"}
+
+// MetaFor returns the FileMeta for the given absolute filename, and whether
+// it is an existing file in one of the components. For the static files the
+// filesystem for the given language will be used.
+func (s SourceFilesystems) MetaFor(lang, filename string) (FileMeta, bool) {
+	for _, component := range metaComponents {
+		fs, found := s.ForComponent(component)
+		if component == "static" {
+			if lfs, ok := s.Static[lang]; ok {
+				fs, found = lfs, true
+			}
+		}
+		if !found {
+			continue
+		}
+
+		rel := fs.MakePathRelative(filename)
+		if rel == "" {
+			continue
+		}
+
+		fi, err := fs.SourceFs.Stat(filename)
+		if err != nil || fi.IsDir() {
+			return FileMeta{}, false
+		}
 
-// RelContentDir tries to create a path relative to the content root from
-// the given filename. The return value is the path and language code.
-func (b *BaseFs) RelContentDir(filename string) string {
-	for _, dirname := range b.SourceFilesystems.Content.Dirnames {
-		if strings.HasPrefix(filename, dirname) {
-			rel := strings.TrimPrefix(filename, dirname)
-			return strings.TrimPrefix(rel, filePathSeparator)
+		meta := FileMeta{
+			Component: component,
+			Lang:      lang,
+			Path:      strings.TrimPrefix(rel, filePathSeparator),
+			Filename:  filename,
+			ModTime:   fi.ModTime(),
+		}
+
+		if component == "content" {
+			meta.Lang = s.ContentLang(filename)
 		}
+
+		return meta, true
 	}
-	// Either not a content dir or already relative.
-	return filename
+
+	return FileMeta{}, false
 }
 
-// SourceFilesystems contains the different source file systems. These can be
-// composite file systems (theme and project etc.), and they have all root
-// set to the source type the provides: data, i18n, static, layouts.
-type SourceFilesystems struct {
-	Content    *SourceFilesystem
-	Data       *SourceFilesystem
-	I18n       *SourceFilesystem
-	Layouts    *SourceFilesystem
-	Archetypes *SourceFilesystem
-	Assets     *SourceFilesystem
-	Resources  *SourceFilesystem
+// ContentFileInfo describes a content file, see ListContent.
+type ContentFileInfo struct {
+	// The path relative to the content root, e.g. "blog/post.sv.md".
+	Path string
 
-	// This is a unified read-only view of the project's and themes' workdir.
-	Work *SourceFilesystem
+	// The language of the file.
+	Lang string
 
-	// When in multihost we have one static filesystem per language. The sync
-	// static files is currently done outside of the Hugo build (where there is
-	// a concept of a site per language).
-	// When in non-multihost mode there will be one entry in this map with a blank key.
-	Static map[string]*SourceFilesystem
+	// The absolute filename of the file on disk.
+	Filename string
 }
 
-// A SourceFilesystem holds the filesystem for a given source type in Hugo (data,
-// i18n, layouts, static) and additional metadata to be able to use that filesystem
-// in server mode.
-type SourceFilesystem struct {
-	// This is a virtual composite filesystem. It expects path relative to a context.
-	Fs afero.Fs
+// ListContent returns all the files in the content filesystem, with all
+// languages, sorted by path and language.
+func (s SourceFilesystems) ListContent() ([]ContentFileInfo, error) {
+	var files []ContentFileInfo
 
-	// This is the base source filesystem. In real Hugo, this will be the OS filesystem.
-	// Use this if you need to resolve items in Dirnames below.
-	SourceFs afero.Fs
+	err := afero.Walk(s.Content.Fs, "", func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
 
-	// Dirnames is absolute filenames to the directories in this filesystem.
-	Dirnames []string
+		lfi, ok := fi.(*hugofs.LanguageFileInfo)
+		if !ok || lfi.IsDir() {
+			return nil
+		}
 
-	// When syncing a source folder to the target (e.g. /public), this may
-	// be set to publish into a subfolder. This is used for static syncing
-	// in multihost mode.
-	PublishFolder string
-}
+		files = append(files, ContentFileInfo{Path: lfi.Path(), Lang: lfi.Lang(), Filename: lfi.Filename()})
 
-// ContentStaticAssetFs will create a new composite filesystem from the content,
-// static, and asset filesystems. The site language is needed to pick the correct static filesystem.
-// The order is content, static and then assets.
-// TODO(bep) check usage
-func (s SourceFilesystems) ContentStaticAssetFs(lang string) afero.Fs {
-	staticFs := s.StaticFs(lang)
+		return nil
+	})
 
-	base := afero.NewCopyOnWriteFs(s.Assets.Fs, staticFs)
-	return afero.NewCopyOnWriteFs(base, s.Content.Fs)
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Path == files[j].Path {
+			return files[i].Lang < files[j].Lang
+		}
+		return files[i].Path < files[j].Path
+	})
 
+	return files, err
 }
 
-// StaticFs returns the static filesystem for the given language.
-// This can be a composite filesystem.
-func (s SourceFilesystems) StaticFs(lang string) afero.Fs {
-	var staticFs afero.Fs = hugofs.NoOpFs
-
-	if fs, ok := s.Static[lang]; ok {
-		staticFs = fs.Fs
-	} else if fs, ok := s.Static[""]; ok {
-		staticFs = fs.Fs
-	}
+// ComponentPath is a file in a component, see ClassifyEvents.
+type ComponentPath struct {
+	// The language of the file. For content, this is the language returned
+	// by ContentLang. For static, this is the language of the static filesystem,
+	// which is only set in multihost mode.
+	Lang string
 
-	return staticFs
+	// The path relative to the component root, e.g. "blog/post.sv.md".
+	Path string
 }
 
-// StatResource looks for a resource in these filesystems in order: static, assets and finally content.
-// If found in any of them, it returns FileInfo and the relevant filesystem.
-// Any non os.IsNotExist error will be returned.
-// An os.IsNotExist error wil be returned only if all filesystems return such an error.
-// Note that if we only wanted to find the file, we could create a composite Afero fs,
-// but we also need to know which filesystem root it lives in.
-func (s SourceFilesystems) StatResource(lang, filename string) (fi os.FileInfo, fs afero.Fs, err error) {
-	for _, fsToCheck := range []afero.Fs{s.StaticFs(lang), s.Assets.Fs, s.Content.Fs} {
-		fs = fsToCheck
-		fi, err = fs.Stat(filename)
-		if err == nil || !os.IsNotExist(err) {
+// ClassifyEvents groups the given absolute filenames, typically from file
+// system events, by the components they belong to, e.g. "content" or
+// "layouts", with any duplicates removed. A filename belonging to more than one
+// component, e.g. a directory used for both layouts and archetypes, is added
+// to all of them. Filenames outside of the components are skipped.
+// The files do not need to exist, so this also works for removed files.
+func (s SourceFilesystems) ClassifyEvents(events []string) map[string][]ComponentPath {
+	result := make(map[string][]ComponentPath)
+	seen := make(map[string]map[ComponentPath]bool)
+
+	add := func(component string, fs *SourceFilesystem, lang, filename string) {
+		rel := fs.MakePathRelative(filename)
+		if rel == "" {
+			return
+		}
+		if component == "content" {
+			lang = s.ContentLang(filename)
+		}
+
+		cp := ComponentPath{Lang: lang, Path: strings.TrimPrefix(rel, filePathSeparator)}
+		if seen[component] == nil {
+			seen[component] = make(map[ComponentPath]bool)
+		}
+		if seen[component][cp] {
 			return
 		}
+		seen[component][cp] = true
+		result[component] = append(result[component], cp)
 	}
-	// Not found.
-	return
-}
 
-// IsStatic returns true if the given filename is a member of one of the static
-// filesystems.
-func (s SourceFilesystems) IsStatic(filename string) bool {
-	for _, staticFs := range s.Static {
-		if staticFs.Contains(filename) {
-			return true
+	staticLangs := make([]string, 0, len(s.Static))
+	for lang := range s.Static {
+		staticLangs = append(staticLangs, lang)
+	}
+	sort.Strings(staticLangs)
+
+	for _, filename := range events {
+		filename = filepath.Clean(filename)
+		for _, component := range metaComponents {
+			if component == "static" {
+				for _, lang := range staticLangs {
+					add(component, s.Static[lang], lang, filename)
+				}
+				continue
+			}
+			if fs, found := s.ForComponent(component); found {
+				add(component, fs, "", filename)
+			}
 		}
 	}
-	return false
+
+	return result
 }
 
-// IsContent returns true if the given filename is a member of the content filesystem.
-func (s SourceFilesystems) IsContent(filename string) bool {
-	return s.Content.Contains(filename)
+// SectionIndex returns the _index file, e.g. "_index.sv.md", of the given
+// section in the content filesystem for the language lang, falling back to the
+// default content language's _index file, which includes an _index file
+// without any language in its name. It returns false if none is found.
+func (s SourceFilesystems) SectionIndex(section, lang string) (*hugofs.LanguageFileInfo, bool) {
+	fis, err := afero.ReadDir(s.Content.Fs, section)
+	if err != nil {
+		return nil, false
+	}
+
+	var fallback *hugofs.LanguageFileInfo
+
+	for _, fi := range fis {
+		lfi, ok := fi.(*hugofs.LanguageFileInfo)
+		if !ok || lfi.IsDir() || lfi.TranslationBaseName() != "_index" {
+			continue
+		}
+		if lfi.Lang() == lang {
+			return lfi, true
+		}
+		if lfi.Lang() == s.defaultContentLanguage && fallback == nil {
+			fallback = lfi
+		}
+	}
+
+	return fallback, fallback != nil
 }
 
 // IsLayout returns true if the given filename is a member of the layouts filesystem.
@@ -187,6 +1348,12 @@ func (s SourceFilesystems) IsAsset(filename string) bool {
 	return s.Assets.Contains(filename)
 }
 
+// IsWork returns true if the given filename is a member of the work filesystem,
+// i.e. it lives below the project or one of the themes.
+func (s SourceFilesystems) IsWork(filename string) bool {
+	return s.Work.Contains(filename)
+}
+
 // IsI18n returns true if the given filename is a member of the i18n filesystem.
 func (s SourceFilesystems) IsI18n(filename string) bool {
 	return s.I18n.Contains(filename)
@@ -204,39 +1371,141 @@ func (s SourceFilesystems) MakeStaticPathRelative(filename string) string {
 	return ""
 }
 
+// NewSourceFilesystem creates a new SourceFilesystem with fs as its virtual
+// filesystem, made up of the given dirnames (absolute filenames) in sourceFs.
+func NewSourceFilesystem(fs, sourceFs afero.Fs, dirnames []string) *SourceFilesystem {
+	return &SourceFilesystem{
+		Fs:       fs,
+		SourceFs: sourceFs,
+		Dirnames: dirnames,
+	}
+}
+
 // MakePathRelative creates a relative path from the given filename.
 // It will return an empty string if the filename is not a member of this filesystem.
+// If the filename lives in more than one of the dirs, e.g. a theme file in the
+// Work filesystem, the most specific dir wins. The filename may use forward
+// slashes on Windows.
 func (d *SourceFilesystem) MakePathRelative(filename string) string {
+	filename = filepath.FromSlash(filename)
+	var dir, rel string
 	for _, currentPath := range d.Dirnames {
-		if strings.HasPrefix(filename, currentPath) {
-			return strings.TrimPrefix(filename, currentPath)
+		if r, ok := relToDir(currentPath, filename); ok && len(currentPath) > len(dir) {
+			dir, rel = currentPath, r
 		}
 	}
-	return ""
+	return rel
 }
 
-func (d *SourceFilesystem) RealFilename(rel string) string {
+// relToDir returns filename relative to dir, with a leading separator, and
+// whether filename is dir or lives below it. Only whole path elements match,
+// so /p/contentx is not below /p/content.
+func relToDir(dir, filename string) (string, bool) {
+	dir = strings.TrimSuffix(dir, filePathSeparator)
+	if filename == dir {
+		return "", true
+	}
+	if strings.HasPrefix(filename, dir+filePathSeparator) {
+		return filename[len(dir):], true
+	}
+	return "", false
+}
+
+// RealFilename returns the real filename of the given file relative to this
+// filesystem, and whether the file was found. If the filesystem does not know
+// the real filename of an existing file, rel is returned.
+func (d *SourceFilesystem) RealFilename(rel string) (string, bool) {
 	fi, err := d.Fs.Stat(rel)
 	if err != nil {
-		return rel
+		return "", false
 	}
 	if realfi, ok := fi.(hugofs.RealFilenameInfo); ok {
-		return realfi.RealFilename()
+		return realfi.RealFilename(), true
 	}
 
-	return rel
+	return rel, true
+}
+
+// Writable returns whether this filesystem is meant to be written to. Of the
+// component filesystems created by NewBase, this is only true for resources,
+// unless WithReadOnly is set; the others are read-only.
+func (d *SourceFilesystem) Writable() bool {
+	return d.writable
 }
 
 // Contains returns whether the given filename is a member of the current filesystem.
+// The filename may use forward slashes on Windows.
+// On the OS filesystem, symbolic links in both the directory of the filename
+// and Dirnames are also resolved, so e.g. a file event reported with the real
+// path of a symlinked content dir is a member.
 func (d *SourceFilesystem) Contains(filename string) bool {
+	filename = filepath.FromSlash(filename)
 	for _, dir := range d.Dirnames {
-		if strings.HasPrefix(filename, dir) {
+		if _, ok := relToDir(dir, filename); ok {
+			return true
+		}
+	}
+
+	sourceFs := d.SourceFs
+	if rfs, ok := sourceFs.(*dirRoutingFs); ok {
+		sourceFs = rfs.Fs
+	}
+	if _, ok := sourceFs.(*afero.OsFs); !ok {
+		return false
+	}
+
+	d.resolvedDirnamesInit.Do(func() {
+		for _, dir := range d.Dirnames {
+			if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+				d.resolvedDirnames = append(d.resolvedDirnames, resolved)
+			}
+		}
+	})
+
+	if d.containsResolved(filename) {
+		return true
+	}
+
+	dir, err := d.resolveDir(filepath.Dir(filename))
+	if err != nil {
+		return false
+	}
+	resolved := filepath.Join(dir, filepath.Base(filename))
+
+	return resolved != filename && d.containsResolved(resolved)
+}
+
+func (d *SourceFilesystem) containsResolved(filename string) bool {
+	for _, dir := range d.resolvedDirnames {
+		if filename == dir || strings.HasPrefix(filename, dir+filePathSeparator) {
 			return true
 		}
 	}
 	return false
 }
 
+// resolveDir works as filepath.EvalSymlinks, but caches the result.
+func (d *SourceFilesystem) resolveDir(dir string) (string, error) {
+	d.resolvedDirsMu.Lock()
+	defer d.resolvedDirsMu.Unlock()
+
+	if resolved, found := d.resolvedDirs[dir]; found {
+		return resolved, nil
+	}
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if d.resolvedDirs == nil {
+		d.resolvedDirs = make(map[string]string)
+	}
+	d.resolvedDirs[dir] = resolved
+
+	return resolved, nil
+}
+
 // RealDirs gets a list of absolute paths to directories starting from the given
 // path.
 func (d *SourceFilesystem) RealDirs(from string) []string {
@@ -260,36 +1529,122 @@ func WithBaseFs(b *BaseFs) func(*BaseFs) error {
 	}
 }
 
-func newRealBase(base afero.Fs) afero.Fs {
-	return hugofs.NewBasePathRealFilenameFs(base.(*afero.BasePathFs))
+// WithPublishExcludes makes any file published matching one of the given glob
+// patterns, e.g. "*.map", silently dropped. See hugofs.NewGlobIgnoreFs for
+// the pattern syntax.
+func WithPublishExcludes(patterns []string) func(*BaseFs) error {
+	return func(b *BaseFs) error {
+		if len(patterns) > 0 {
+			b.PublishFs = hugofs.NewExcludeWritesFs(b.PublishFs, patterns)
+		}
+		return nil
+	}
+}
 
+// WithOnlyThemes limits the themes added to the filesystems to the ones named,
+// which is useful to find out which theme is causing a problem. The project
+// itself is always included. Any reused theme filesystem is rebuilt, so this
+// option should be applied after WithBaseFs.
+func WithOnlyThemes(themes ...string) func(*BaseFs) error {
+	return func(b *BaseFs) error {
+		if themes == nil {
+			themes = []string{}
+		}
+		b.onlyThemes = themes
+		b.themeFs = nil
+		b.AbsThemeDirs = nil
+		return nil
+	}
 }
 
-// NewBase builds the filesystems used by Hugo given the paths and options provided.NewBase
-func NewBase(p *paths.Paths, options ...func(*BaseFs) error) (*BaseFs, error) {
-	fs := p.Fs
+// WithComponents limits the component filesystems built to the ones named,
+// e.g. "content" and "data", for tools that only need to read some of them.
+// The others are set to a hugofs.NoOpFs. The work filesystem is always built.
+func WithComponents(components ...string) func(*BaseFs) error {
+	return func(b *BaseFs) error {
+		b.components = make(map[string]bool)
+		for _, component := range components {
+			found := false
+			for _, c := range metaComponents {
+				if c == component {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown component %q", component)
+			}
+			b.components[component] = true
+		}
+		return nil
+	}
+}
+
+// WithReadOnly makes the resources filesystem read-only, so any attempt to
+// write to it, e.g. the resource cache, fails instead of modifying the source
+// tree. Missing directories are not created. This is useful for CI builds.
+func WithReadOnly() func(*BaseFs) error {
+	return func(b *BaseFs) error {
+		b.readOnly = true
+		return nil
+	}
+}
 
-	publishFs := afero.NewBasePathFs(fs.Destination, p.AbsPublishDir)
+// isIncluded reports whether the given component should be built.
+func (b *BaseFs) isIncluded(component string) bool {
+	return b.components == nil || component == "" || b.components[component]
+}
 
-	contentFs, absContentDirs, err := createContentFs(fs.Source, p.WorkingDir, p.DefaultContentLanguage, p.Languages)
-	if err != nil {
-		return nil, err
+// filterThemes returns the themes in themes named in only, keeping the
+// original order. A nil only returns all themes.
+func filterThemes(themes []paths.ThemeConfig, only []string) ([]paths.ThemeConfig, error) {
+	if only == nil {
+		return themes, nil
 	}
 
-	// Make sure we don't have any overlapping content dirs. That will never work.
-	for i, d1 := range absContentDirs {
-		for j, d2 := range absContentDirs {
-			if i == j {
-				continue
+	var filtered []paths.ThemeConfig
+	for _, name := range only {
+		found := false
+		for _, theme := range themes {
+			if theme.Name == name {
+				found = true
+				break
 			}
-			if strings.HasPrefix(d1, d2) || strings.HasPrefix(d2, d1) {
-				return nil, fmt.Errorf("found overlapping content dirs (%q and %q)", d1, d2)
+		}
+		if !found {
+			return nil, fmt.Errorf("theme %q not found", name)
+		}
+	}
+
+	for _, theme := range themes {
+		for _, name := range only {
+			if theme.Name == name {
+				filtered = append(filtered, theme)
+				break
 			}
 		}
 	}
 
+	return filtered, nil
+}
+
+func newRealBase(base afero.Fs) afero.Fs {
+	return hugofs.NewBasePathRealFilenameFs(base.(*afero.BasePathFs))
+
+}
+
+// NewBase builds the filesystems used by Hugo given the paths and options provided.NewBase
+func NewBase(p *paths.Paths, options ...func(*BaseFs) error) (*BaseFs, error) {
+	fs := p.Fs
+
+	publishFs := newRealBase(afero.NewBasePathFs(fs.Destination, p.AbsPublishDir))
+	_, publishOnOs := fs.Destination.(*afero.OsFs)
+
 	b := &BaseFs{
-		PublishFs: publishFs,
+		PublishFs:     publishFs,
+		absPublishDir: p.AbsPublishDir,
+		workingDir:    p.WorkingDir,
+		publishOnOs:   publishOnOs,
 	}
 
 	for _, opt := range options {
@@ -298,7 +1653,29 @@ func NewBase(p *paths.Paths, options ...func(*BaseFs) error) (*BaseFs, error) {
 		}
 	}
 
-	builder := newSourceFilesystemsBuilder(p, b)
+	themes, err := filterThemes(p.AllThemes, b.onlyThemes)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		contentFs     afero.Fs = hugofs.NoOpFs
+		contentMounts []mount
+	)
+
+	if b.isIncluded("content") {
+		contentFs, contentMounts, err = createContentFs(fs.Source, p.WorkingDir, p.DefaultContentLanguage, p.Languages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	absContentDirs := make([]string, len(contentMounts))
+	for i, m := range contentMounts {
+		absContentDirs[i] = m.source
+	}
+
+	builder := newSourceFilesystemsBuilder(p, themes, b)
 	sourceFilesystems, err := builder.Build()
 	if err != nil {
 		return nil, err
@@ -310,28 +1687,48 @@ func NewBase(p *paths.Paths, options ...func(*BaseFs) error) (*BaseFs, error) {
 		Dirnames: absContentDirs,
 	}
 
+	sourceFilesystems.contentMounts = contentMounts
+	sourceFilesystems.defaultContentLanguage = p.DefaultContentLanguage
+	sourceFilesystems.languages = make(map[string]bool)
+	for _, l := range p.Languages {
+		sourceFilesystems.languages[l.Lang] = true
+	}
+
 	b.SourceFilesystems = sourceFilesystems
 	b.themeFs = builder.themeFs
 	b.AbsThemeDirs = builder.absThemeDirs
+	b.mounts = append(contentMounts, builder.mounts...)
+	b.missingDirs = builder.missingDirs
+
+	// The content dirs are mounted even if they do not exist.
+	for _, dir := range absContentDirs {
+		if !builder.existsInSource(dir) {
+			b.missingDirs = append(b.missingDirs, dir)
+		}
+	}
 
 	return b, nil
 }
 
 type sourceFilesystemsBuilder struct {
 	p            *paths.Paths
+	themes       []paths.ThemeConfig
 	result       *SourceFilesystems
 	themeFs      afero.Fs
 	hasTheme     bool
 	absThemeDirs []string
+	mounts       []mount
+	missingDirs  []string
+	base         *BaseFs
 }
 
-func newSourceFilesystemsBuilder(p *paths.Paths, b *BaseFs) *sourceFilesystemsBuilder {
-	return &sourceFilesystemsBuilder{p: p, themeFs: b.themeFs, absThemeDirs: b.AbsThemeDirs, result: &SourceFilesystems{}}
+func newSourceFilesystemsBuilder(p *paths.Paths, themes []paths.ThemeConfig, b *BaseFs) *sourceFilesystemsBuilder {
+	return &sourceFilesystemsBuilder{p: p, themes: themes, themeFs: b.themeFs, absThemeDirs: b.AbsThemeDirs, base: b, result: &SourceFilesystems{}}
 }
 
 func (b *sourceFilesystemsBuilder) Build() (*SourceFilesystems, error) {
-	if b.themeFs == nil && b.p.ThemeSet() {
-		themeFs, absThemeDirs, err := createThemesOverlayFs(b.p)
+	if b.themeFs == nil && len(b.themes) > 0 {
+		themeFs, absThemeDirs, err := createThemesOverlayFs(b.p, b.themes)
 		if err != nil {
 			return nil, err
 		}
@@ -404,6 +1801,14 @@ func (b *sourceFilesystemsBuilder) createFs(
 		SourceFs: b.p.Fs.Source,
 	}
 
+	// The work filesystem is not a component.
+	component := themeFolder
+
+	if !b.base.isIncluded(component) {
+		s.Fs = hugofs.NoOpFs
+		return s, nil
+	}
+
 	if themeFolder == "" {
 		themeFolder = filePathSeparator
 	}
@@ -416,6 +1821,10 @@ func (b *sourceFilesystemsBuilder) createFs(
 		}
 	}
 
+	if b.base.readOnly {
+		mkdir, readOnly = false, true
+	}
+
 	var fs afero.Fs
 
 	absDir := b.p.AbsPathify(dir)
@@ -429,6 +1838,9 @@ func (b *sourceFilesystemsBuilder) createFs(
 	if existsInSource {
 		fs = newRealBase(afero.NewBasePathFs(b.p.Fs.Source, absDir))
 		s.Dirnames = []string{absDir}
+		b.addMount(component, "", absDir, "", "")
+	} else {
+		b.addMissing(absDir)
 	}
 
 	if b.hasTheme {
@@ -446,6 +1858,9 @@ func (b *sourceFilesystemsBuilder) createFs(
 			absThemeFolderDir := filepath.Join(absThemeDir, themeFolder)
 			if b.existsInSource(absThemeFolderDir) {
 				s.Dirnames = append(s.Dirnames, absThemeFolderDir)
+				b.addMount(component, "", absThemeFolderDir, filepath.Base(absThemeDir), "")
+			} else {
+				b.addMissing(absThemeFolderDir)
 			}
 		}
 	}
@@ -456,6 +1871,7 @@ func (b *sourceFilesystemsBuilder) createFs(
 		s.Fs = afero.NewReadOnlyFs(fs)
 	} else {
 		s.Fs = fs
+		s.writable = true
 	}
 
 	return s, nil
@@ -468,6 +1884,11 @@ func (b *sourceFilesystemsBuilder) createRootMappingFs(dirKey, themeFolder strin
 		SourceFs: b.p.Fs.Source,
 	}
 
+	if !b.base.isIncluded(themeFolder) {
+		s.Fs = hugofs.NoOpFs
+		return s, nil
+	}
+
 	projectDir := b.p.Cfg.GetString(dirKey)
 	if projectDir == "" {
 		return nil, fmt.Errorf("config %q not set", dirKey)
@@ -479,14 +1900,20 @@ func (b *sourceFilesystemsBuilder) createRootMappingFs(dirKey, themeFolder strin
 	if b.existsInSource(to) {
 		s.Dirnames = []string{to}
 		fromTo = []string{projectVirtualFolder, to}
+		b.addMount(themeFolder, projectVirtualFolder, to, "", "")
+	} else {
+		b.addMissing(to)
 	}
 
-	for _, theme := range b.p.AllThemes {
+	for _, theme := range b.themes {
 		to := b.p.AbsPathify(filepath.Join(b.p.ThemesDir, theme.Name, themeFolder))
 		if b.existsInSource(to) {
 			s.Dirnames = append(s.Dirnames, to)
 			from := theme
 			fromTo = append(fromTo, from.Name, to)
+			b.addMount(themeFolder, from.Name, to, theme.Name, "")
+		} else {
+			b.addMissing(to)
 		}
 	}
 
@@ -499,12 +1926,27 @@ func (b *sourceFilesystemsBuilder) createRootMappingFs(dirKey, themeFolder strin
 	if err != nil {
 		return nil, err
 	}
+	// A file in more than one of the roots is listed once, the project's
+	// or the first theme's.
+	fs.HideShadowed = true
 
 	s.Fs = afero.NewReadOnlyFs(fs)
 
 	return s, nil
 }
 
+func (b *sourceFilesystemsBuilder) addMount(component, target, source, theme, lang string) {
+	if component == "" {
+		return
+	}
+	b.mounts = append(b.mounts, newMount(component, target, source, theme, lang))
+}
+
+// addMissing records a directory left out as it does not exist.
+func (b *sourceFilesystemsBuilder) addMissing(abspath string) {
+	b.missingDirs = append(b.missingDirs, abspath)
+}
+
 func (b *sourceFilesystemsBuilder) existsInSource(abspath string) bool {
 	exists, _ := afero.Exists(b.p.Fs.Source, abspath)
 	return exists
@@ -515,6 +1957,14 @@ func (b *sourceFilesystemsBuilder) createStaticFs() error {
 	ms := make(map[string]*SourceFilesystem)
 	b.result.Static = ms
 
+	if !b.base.isIncluded("static") {
+		ms[""] = &SourceFilesystem{
+			SourceFs: b.p.Fs.Source,
+			Fs:       hugofs.NoOpFs,
+		}
+		return nil
+	}
+
 	if isMultihost {
 		for _, l := range b.p.Languages {
 			s := &SourceFilesystem{
@@ -528,10 +1978,12 @@ func (b *sourceFilesystemsBuilder) createStaticFs() error {
 			for _, dir := range staticDirs {
 				absDir := b.p.AbsPathify(dir)
 				if !b.existsInSource(absDir) {
+					b.addMissing(absDir)
 					continue
 				}
 
 				s.Dirnames = append(s.Dirnames, absDir)
+				b.addMount("static", "", absDir, "", l.Lang)
 			}
 
 			fs, err := createOverlayFs(b.p.Fs.Source, s.Dirnames)
@@ -543,7 +1995,13 @@ func (b *sourceFilesystemsBuilder) createStaticFs() error {
 				themeFolder := "static"
 				fs = afero.NewCopyOnWriteFs(newRealBase(afero.NewBasePathFs(b.themeFs, themeFolder)), fs)
 				for _, absThemeDir := range b.absThemeDirs {
-					s.Dirnames = append(s.Dirnames, filepath.Join(absThemeDir, themeFolder))
+					absThemeFolderDir := filepath.Join(absThemeDir, themeFolder)
+					// The theme static dir is mounted even if it does not exist.
+					if !b.existsInSource(absThemeFolderDir) {
+						b.addMissing(absThemeFolderDir)
+					}
+					s.Dirnames = append(s.Dirnames, absThemeFolderDir)
+					b.addMount("static", "", absThemeFolderDir, filepath.Base(absThemeDir), l.Lang)
 				}
 			}
 
@@ -573,9 +2031,11 @@ func (b *sourceFilesystemsBuilder) createStaticFs() error {
 	for _, dir := range staticDirs {
 		absDir := b.p.AbsPathify(dir)
 		if !b.existsInSource(absDir) {
+			b.addMissing(absDir)
 			continue
 		}
 		s.Dirnames = append(s.Dirnames, absDir)
+		b.addMount("static", "", absDir, "", "")
 	}
 
 	fs, err := createOverlayFs(b.p.Fs.Source, s.Dirnames)
@@ -587,7 +2047,13 @@ func (b *sourceFilesystemsBuilder) createStaticFs() error {
 		themeFolder := "static"
 		fs = afero.NewCopyOnWriteFs(newRealBase(afero.NewBasePathFs(b.themeFs, themeFolder)), fs)
 		for _, absThemeDir := range b.absThemeDirs {
-			s.Dirnames = append(s.Dirnames, filepath.Join(absThemeDir, themeFolder))
+			absThemeFolderDir := filepath.Join(absThemeDir, themeFolder)
+			// The theme static dir is mounted even if it does not exist.
+			if !b.existsInSource(absThemeFolderDir) {
+				b.addMissing(absThemeFolderDir)
+			}
+			s.Dirnames = append(s.Dirnames, absThemeFolderDir)
+			b.addMount("static", "", absThemeFolderDir, filepath.Base(absThemeDir), "")
 		}
 	}
 
@@ -618,37 +2084,61 @@ func getStringOrStringSlice(cfg config.Provider, key string, id int) []string {
 func createContentFs(fs afero.Fs,
 	workingDir,
 	defaultContentLanguage string,
-	languages langs.Languages) (afero.Fs, []string, error) {
+	languages langs.Languages) (afero.Fs, []mount, error) {
 
 	var contentLanguages langs.Languages
 	var contentDirSeen = make(map[string]bool)
 	languageSet := make(map[string]bool)
 
+	absContentDir := func(language *langs.Language) string {
+		return filepath.Clean(paths.AbsPathify(workingDir, language.ContentDir))
+	}
+
 	// The default content language needs to be first.
+	// Note that several languages may share the same content dir. It will
+	// then be mounted once, and the language of each file will be
+	// determined from its filename, e.g. "post.sv.md".
 	for _, language := range languages {
+		if language.Lang == "" {
+			return nil, nil, fmt.Errorf("no language set for content dir %q", absContentDir(language))
+		}
 		if language.Lang == defaultContentLanguage {
 			contentLanguages = append(contentLanguages, language)
-			contentDirSeen[language.ContentDir] = true
+			contentDirSeen[absContentDir(language)] = true
 		}
 		languageSet[language.Lang] = true
 	}
 
 	for _, language := range languages {
-		if contentDirSeen[language.ContentDir] {
+		if contentDirSeen[absContentDir(language)] {
 			continue
 		}
 		if language.ContentDir == "" {
+			if defaultContentLanguage == "" {
+				return nil, nil, fmt.Errorf("no content dir set for language %q and no defaultContentLanguage to fall back to", language.Lang)
+			}
 			language.ContentDir = defaultContentLanguage
 		}
-		contentDirSeen[language.ContentDir] = true
+		contentDirSeen[absContentDir(language)] = true
 		contentLanguages = append(contentLanguages, language)
 
 	}
 
-	var absContentDirs []string
+	// Make sure we don't have any overlapping content dirs. That will never work,
+	// as which language a file belongs to would depend on the mount order.
+	for i, l1 := range contentLanguages {
+		for _, l2 := range contentLanguages[i+1:] {
+			d1, d2 := absContentDir(l1), absContentDir(l2)
+			if strings.HasPrefix(d1+filePathSeparator, d2+filePathSeparator) || strings.HasPrefix(d2+filePathSeparator, d1+filePathSeparator) {
+				return nil, nil, fmt.Errorf("found overlapping content dirs: %q for language %q and %q for language %q", d1, l1.Lang, d2, l2.Lang)
+			}
+		}
+	}
+
+	var mounts []mount
 
-	fs, err := createContentOverlayFs(fs, workingDir, contentLanguages, languageSet, &absContentDirs)
-	return fs, absContentDirs, err
+	fs, err := createContentOverlayFs(fs, workingDir, contentLanguages, languageSet, &mounts)
+	return fs, mounts, err
 
 }
 
@@ -656,7 +2146,7 @@ func createContentOverlayFs(source afero.Fs,
 	workingDir string,
 	languages langs.Languages,
 	languageSet map[string]bool,
-	absContentDirs *[]string) (afero.Fs, error) {
+	mounts *[]mount) (afero.Fs, error) {
 	if len(languages) == 0 {
 		return source, nil
 	}
@@ -682,14 +2172,14 @@ func createContentOverlayFs(source afero.Fs,
 		return nil, fmt.Errorf("invalid content dir %q: Path is too short", absContentDir)
 	}
 
-	*absContentDirs = append(*absContentDirs, absContentDir)
+	*mounts = append(*mounts, newMount("content", "", absContentDir, "", language.Lang))
 
 	overlay := hugofs.NewLanguageFs(language.Lang, languageSet, afero.NewBasePathFs(source, absContentDir))
 	if len(languages) == 1 {
 		return overlay, nil
 	}
 
-	base, err := createContentOverlayFs(source, workingDir, languages[1:], languageSet, absContentDirs)
+	base, err := createContentOverlayFs(source, workingDir, languages[1:], languageSet, mounts)
 	if err != nil {
 		return nil, err
 	}
@@ -698,12 +2188,10 @@ func createContentOverlayFs(source afero.Fs,
 
 }
 
-func createThemesOverlayFs(p *paths.Paths) (afero.Fs, []string, error) {
-
-	themes := p.AllThemes
+func createThemesOverlayFs(p *paths.Paths, themes []paths.ThemeConfig) (afero.Fs, []string, error) {
 
 	if len(themes) == 0 {
-		panic("AllThemes not set")
+		panic("no themes set")
 	}
 
 	themesDir := p.AbsPathify(p.ThemesDir)
@@ -14,12 +14,19 @@
 package filesystems
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gohugoio/hugo/htesting/tempdir"
 	"github.com/gohugoio/hugo/langs"
 
 	"github.com/spf13/afero"
@@ -157,6 +164,206 @@ func createConfig() *viper.Viper {
 	return v
 }
 
+func TestPublishExcludes(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	v.Set("workingDir", filepath.FromSlash("/my/work"))
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p, WithPublishExcludes([]string{"**/*.map"}))
+	assert.NoError(err)
+
+	assert.NoError(afero.WriteFile(bfs.PublishFs, filepath.FromSlash("js/main.js"), []byte("js"), 0755))
+	assert.NoError(afero.WriteFile(bfs.PublishFs, filepath.FromSlash("js/main.js.map"), []byte("map"), 0755))
+
+	publishDir := filepath.Join(p.AbsPublishDir, "js")
+	checkFileContent(fs.Destination, filepath.Join(publishDir, "main.js"), assert, "js")
+	_, err = fs.Destination.Stat(filepath.Join(publishDir, "main.js.map"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestPublishFsOutsidePublishDir(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	v.Set("workingDir", filepath.FromSlash("/my/work"))
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	assert.NoError(afero.WriteFile(bfs.PublishFs, "index.html", []byte("index"), 0755))
+	checkFileContent(fs.Destination, filepath.Join(p.AbsPublishDir, "index.html"), assert, "index")
+
+	for _, name := range []string{filepath.FromSlash("../public-secrets/key.txt"), filepath.FromSlash("../../work/config.toml")} {
+		err := afero.WriteFile(bfs.PublishFs, name, []byte("escaped"), 0755)
+		assert.True(os.IsNotExist(err), name)
+	}
+
+	_, err = fs.Destination.Stat(filepath.FromSlash("/my/work/public-secrets/key.txt"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestMergePublish(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	v.Set("workingDir", filepath.FromSlash("/my/work"))
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	afero.WriteFile(bfs.PublishFs, "index.html", []byte("old home"), 0755)
+	afero.WriteFile(bfs.PublishFs, filepath.FromSlash("about/index.html"), []byte("about"), 0755)
+
+	staging := afero.NewMemMapFs()
+	afero.WriteFile(staging, "index.html", []byte("new home"), 0755)
+	afero.WriteFile(staging, filepath.FromSlash("blog/2019/post/index.html"), []byte("post"), 0755)
+	afero.WriteFile(staging, filepath.FromSlash("blog/index.html"), []byte("blog"), 0755)
+	afero.WriteFile(staging, "unlisted.html", []byte("unlisted"), 0755)
+
+	assert.NoError(bfs.MergePublish(staging, []string{"index.html", "blog"}))
+
+	checkFileContent(bfs.PublishFs, "index.html", assert, "new home")
+	checkFileContent(bfs.PublishFs, filepath.FromSlash("about/index.html"), assert, "about")
+	checkFileContent(bfs.PublishFs, filepath.FromSlash("blog/index.html"), assert, "blog")
+	checkFileContent(bfs.PublishFs, filepath.FromSlash("blog/2019/post/index.html"), assert, "post")
+	_, err = bfs.PublishFs.Stat("unlisted.html")
+	assert.True(os.IsNotExist(err))
+
+	assert.Error(bfs.MergePublish(staging, []string{"nope.html"}))
+}
+
+func TestCleanPublish(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, filename := range []string{"index.html", "CNAME", ".git/config", "blog/index.html", "blog/keep.txt", "blog/sub/a.html"} {
+		afero.WriteFile(bfs.PublishFs, filepath.FromSlash(filename), []byte("content"), 0755)
+	}
+
+	assert.NoError(bfs.CleanPublish([]string{".git", "CNAME", filepath.FromSlash("blog/keep.txt")}))
+
+	for _, test := range []struct {
+		filename string
+		exists   bool
+	}{
+		{"CNAME", true},
+		{".git/config", true},
+		{"blog/keep.txt", true},
+		{"index.html", false},
+		{"blog/index.html", false},
+		{"blog/sub", false},
+	} {
+		_, err := bfs.PublishFs.Stat(filepath.FromSlash(test.filename))
+		assert.Equal(test.exists, err == nil, test.filename)
+	}
+
+	// The project itself must not be removed.
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "config.toml"), []byte("content"), 0755)
+	for _, publishDir := range []string{".", "..", filepath.FromSlash("/")} {
+		v.Set("publishDir", publishDir)
+		p, err := paths.New(fs, v)
+		assert.NoError(err)
+		bfs, err := NewBase(p)
+		assert.NoError(err)
+		assert.Error(bfs.CleanPublish(nil), publishDir)
+	}
+	_, err = fs.Destination.Stat(filepath.Join(workingDir, "config.toml"))
+	assert.NoError(err)
+}
+
+func TestCleanPublishSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestCleanPublishSymlink as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	d, clean, err := tempdir.Create(hugofs.Os, "hugo-clean-publish-symlink")
+	assert.NoError(err)
+	defer clean()
+
+	workingDir := filepath.Join(d, "work")
+	outsideDir := filepath.Join(d, "outside")
+	assert.NoError(os.MkdirAll(filepath.Join(workingDir, "realpublic"), 0755))
+	assert.NoError(os.MkdirAll(outsideDir, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(outsideDir, "file.txt"), []byte("outside"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(workingDir, "config.toml"), []byte("config"), 0755))
+	assert.NoError(os.Symlink(outsideDir, filepath.Join(workingDir, "outside")))
+	assert.NoError(os.Symlink(d, filepath.Join(workingDir, "parent")))
+	assert.NoError(ioutil.WriteFile(filepath.Join(workingDir, "realpublic", "index.html"), []byte("index"), 0755))
+
+	newBase := func(publishDir string) *BaseFs {
+		v := createConfig()
+		v.Set("workingDir", workingDir)
+		v.Set("publishDir", publishDir)
+		p, err := paths.New(hugofs.NewFrom(hugofs.Os, v), v)
+		assert.NoError(err)
+		bfs, err := NewBase(p)
+		assert.NoError(err)
+		return bfs
+	}
+
+	assert.Error(newBase("outside").CleanPublish(nil))
+	assert.Error(newBase("parent").CleanPublish(nil))
+	assert.NoError(newBase("realpublic").CleanPublish(nil))
+	assert.NoError(newBase("nonexisting").CleanPublish(nil))
+
+	for _, filename := range []string{filepath.Join(outsideDir, "file.txt"), filepath.Join(workingDir, "config.toml")} {
+		_, err := os.Stat(filename)
+		assert.NoError(err, filename)
+	}
+	_, err = os.Stat(filepath.Join(workingDir, "realpublic", "index.html"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestWritable(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	v.Set("workingDir", filepath.FromSlash("/my/work"))
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.FromSlash("/my/work/mylayouts/a.html"), []byte("content"), 0755)
+	afero.WriteFile(fs.Source, filepath.FromSlash("/my/work/themes/mytheme/layouts/b.html"), []byte("content"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	assert.True(bfs.Resources.Writable())
+	assert.NoError(afero.WriteFile(bfs.Resources.Fs, "r.txt", []byte("content"), 0755))
+
+	for name, sfs := range map[string]*SourceFilesystem{
+		"layouts":    bfs.Layouts,
+		"archetypes": bfs.Archetypes,
+		"assets":     bfs.Assets,
+		"data":       bfs.Data,
+		"i18n":       bfs.I18n,
+		"work":       bfs.Work,
+		"static":     bfs.Static[""],
+	} {
+		assert.False(sfs.Writable(), name)
+	}
+	assert.Error(afero.WriteFile(bfs.Layouts.Fs, "c.html", []byte("content"), 0755))
+}
+
 func TestNewBaseFsEmpty(t *testing.T) {
 	assert := require.New(t)
 	v := createConfig()
@@ -165,150 +372,1420 @@ func TestNewBaseFsEmpty(t *testing.T) {
 	assert.NoError(err)
 	bfs, err := NewBase(p)
 	assert.NoError(err)
-	assert.NotNil(bfs)
-	assert.Equal(hugofs.NoOpFs, bfs.Archetypes.Fs)
-	assert.Equal(hugofs.NoOpFs, bfs.Layouts.Fs)
-	assert.Equal(hugofs.NoOpFs, bfs.Data.Fs)
-	assert.Equal(hugofs.NoOpFs, bfs.Assets.Fs)
-	assert.Equal(hugofs.NoOpFs, bfs.I18n.Fs)
-	assert.NotNil(bfs.Work.Fs)
-	assert.NotNil(bfs.Content.Fs)
-	assert.NotNil(bfs.Static)
-}
-
-func TestRealDirs(t *testing.T) {
-	assert := require.New(t)
-	v := createConfig()
-	fs := hugofs.NewDefault(v)
-	sfs := fs.Source
+	assert.NotNil(bfs)
+	assert.Equal(hugofs.NoOpFs, bfs.Archetypes.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.Layouts.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.Data.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.Assets.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.I18n.Fs)
+	assert.NotNil(bfs.Work.Fs)
+	assert.NotNil(bfs.Content.Fs)
+	assert.NotNil(bfs.Static)
+}
+
+func TestDescribeMounts(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", "l.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mycontent", "c.md"), []byte("content"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mystatic", "s.txt"), []byte("static"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "layouts", "t.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "archetypes", "a.md"), []byte("archetype"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	lines := bfs.DescribeMounts()
+
+	assert.Contains(lines, fmt.Sprintf("content: content <- %s (project, lang=en, watch)", filepath.Join(workingDir, "mycontent")))
+	assert.Contains(lines, fmt.Sprintf("layouts: layouts <- %s (project, watch)", filepath.Join(workingDir, "mylayouts")))
+	assert.Contains(lines, fmt.Sprintf("layouts: layouts <- %s (mytheme, watch)", filepath.Join(workingDir, "themes", "mytheme", "layouts")))
+	assert.Contains(lines, fmt.Sprintf("archetypes: archetypes <- %s (mytheme)", filepath.Join(workingDir, "themes", "mytheme", "archetypes")))
+	assert.Contains(lines, fmt.Sprintf("static: static <- %s (project, watch)", filepath.Join(workingDir, "mystatic")))
+	assert.Contains(lines, fmt.Sprintf("static: static <- %s (mytheme, watch)", filepath.Join(workingDir, "themes", "mytheme", "static")))
+}
+
+func TestMountTableJSON(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", "l.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mycontent", "c.md"), []byte("content"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "layouts", "t.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "archetypes", "a.md"), []byte("archetype"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	b, err := bfs.MountTableJSON()
+	assert.NoError(err)
+
+	var entries []map[string]interface{}
+	assert.NoError(json.Unmarshal(b, &entries))
+	assert.Len(entries, len(bfs.DescribeMounts()))
+
+	entry := func(component, source, lang string, watch bool, origin string) map[string]interface{} {
+		m := map[string]interface{}{
+			"component": component,
+			"source":    source,
+			"target":    component,
+			"watch":     watch,
+			"origin":    origin,
+		}
+		if lang != "" {
+			m["lang"] = lang
+		}
+		return m
+	}
+
+	assert.Contains(entries, entry("content", filepath.Join(workingDir, "mycontent"), "en", true, "project"))
+	assert.Contains(entries, entry("layouts", filepath.Join(workingDir, "mylayouts"), "", true, "project"))
+	assert.Contains(entries, entry("layouts", filepath.Join(workingDir, "themes", "mytheme", "layouts"), "", true, "mytheme"))
+	assert.Contains(entries, entry("archetypes", filepath.Join(workingDir, "themes", "mytheme", "archetypes"), "", false, "mytheme"))
+}
+
+func TestWatchDirs(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	for _, dir := range []string{"mycontent", "mylayouts", "mystatic", "myarchetypes", filepath.Join("themes", "mytheme", "static")} {
+		afero.WriteFile(fs.Source, filepath.Join(workingDir, dir, "file.txt"), []byte("content"), 0755)
+	}
+	// Mounted twice.
+	v.Set("staticDir", []string{"mystatic", "mystatic/"})
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	dirs := bfs.WatchDirs()
+	assert.Equal([]string{
+		filepath.Join(workingDir, "mycontent"),
+		filepath.Join(workingDir, "mylayouts"),
+		filepath.Join(workingDir, "mystatic"),
+		filepath.Join(workingDir, "themes", "mytheme", "static"),
+	}, dirs)
+}
+
+func TestMissingDirs(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	for _, dir := range []string{"mycontent", "mylayouts", "mystatic", "mydata", "myarchetypes", "myassets", filepath.Join("themes", "mytheme", "layouts")} {
+		afero.WriteFile(fs.Source, filepath.Join(workingDir, dir, "file.txt"), []byte("content"), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	themeDir := filepath.Join(workingDir, "themes", "mytheme")
+
+	assert.Equal([]string{
+		filepath.Join(workingDir, "myi18n"),
+		filepath.Join(themeDir, "archetypes"),
+		filepath.Join(themeDir, "assets"),
+		filepath.Join(themeDir, "data"),
+		filepath.Join(themeDir, "i18n"),
+		filepath.Join(themeDir, "resources"),
+		filepath.Join(themeDir, "static"),
+	}, bfs.MissingDirs())
+
+	_, err = bfs.I18n.Fs.Stat(filepath.Join(projectVirtualFolder, "en.toml"))
+	assert.Error(err)
+
+	// Create one of them and rebuild.
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "myi18n", "en.toml"), []byte("hello"), 0755)
+
+	bfs, err = NewBase(p)
+	assert.NoError(err)
+	assert.NotContains(bfs.MissingDirs(), filepath.Join(workingDir, "myi18n"))
+	checkFileContent(bfs.I18n.Fs, filepath.Join(projectVirtualFolder, "en.toml"), assert, "hello")
+
+	// The content dirs.
+	v.Set("contentDir", "nocontent")
+	p, err = paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err = NewBase(p)
+	assert.NoError(err)
+	assert.Contains(bfs.MissingDirs(), filepath.Join(workingDir, "nocontent"))
+}
+
+func TestWatchFilenames(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+	// Shared by two components.
+	v.Set("staticDir", "shared")
+	v.Set("assetDir", "shared")
+
+	for _, dir := range []string{"shared", "mylayouts", "myarchetypes", filepath.Join("themes", "mytheme", "layouts")} {
+		afero.WriteFile(fs.Source, filepath.Join(workingDir, dir, "file.txt"), []byte("content"), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	assert.Equal([]string{
+		filepath.Join(workingDir, "mycontent"),
+		filepath.Join(workingDir, "mylayouts"),
+		filepath.Join(workingDir, "shared"),
+		filepath.Join(workingDir, "themes", "mytheme", "layouts"),
+		filepath.Join(workingDir, "themes", "mytheme", "static"),
+	}, bfs.WatchFilenames())
+}
+
+func TestWatchConflicts(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("archetypeDir", "mylayouts")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", "l.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mystatic", "s.txt"), []byte("static"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	assert.Equal([]string{
+		fmt.Sprintf("%s: watched as layouts (project), not watched as archetypes (project)", filepath.Join(workingDir, "mylayouts")),
+	}, bfs.WatchConflicts())
+
+	v.Set("archetypeDir", "myarchetypes")
+	p, err = paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err = NewBase(p)
+	assert.NoError(err)
+	assert.Empty(bfs.WatchConflicts())
+}
+
+func TestSourceFanout(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("staticDir", "shared")
+	v.Set("assetDir", "shared")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "shared", "a.txt"), []byte("shared"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	assert.Equal(map[string][]string{
+		filepath.Join(workingDir, "shared"): {"assets", "static"},
+	}, bfs.SourceFanout())
+
+	v.Set("assetDir", "myassets")
+	p, err = paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err = NewBase(p)
+	assert.NoError(err)
+	assert.Len(bfs.SourceFanout(), 0)
+}
+
+func TestAbsThemeDirs(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", "l.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "assets", "a.txt"), []byte("asset"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	expected := []string{filepath.Join(workingDir, "themes", "mytheme")}
+	assert.Equal(expected, bfs.AbsThemeDirs)
+
+	// Reused.
+	bfs2, err := NewBase(p, WithBaseFs(bfs))
+	assert.NoError(err)
+	assert.Equal(expected, bfs2.AbsThemeDirs)
+	assert.Equal([]string{filepath.Join(workingDir, "themes", "mytheme", "assets")}, bfs2.Assets.Dirnames)
+}
+
+func TestOnlyThemes(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", []string{"atheme", "btheme", "ctheme"})
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", "project.html"), []byte("project"), 0755)
+	for _, theme := range []string{"atheme", "btheme", "ctheme"} {
+		afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", theme, "layouts", theme+".html"), []byte(theme), 0755)
+		afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", theme, "data", theme+".toml"), []byte("a = 1"), 0755)
+		afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", theme, "static", theme+".txt"), []byte(theme), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+	assert.Len(bfs.AbsThemeDirs, 3)
+
+	bfs, err = NewBase(p, WithBaseFs(bfs), WithOnlyThemes("btheme"))
+	assert.NoError(err)
+	assert.Equal([]string{filepath.Join(workingDir, "themes", "btheme")}, bfs.AbsThemeDirs)
+
+	for _, test := range []struct {
+		fs       afero.Fs
+		filename string
+		exists   bool
+	}{
+		{bfs.Layouts.Fs, "project.html", true},
+		{bfs.Layouts.Fs, "btheme.html", true},
+		{bfs.Layouts.Fs, "atheme.html", false},
+		{bfs.Layouts.Fs, "ctheme.html", false},
+		{bfs.Data.Fs, filepath.Join("btheme", "btheme.toml"), true},
+		{bfs.Data.Fs, filepath.Join("atheme", "atheme.toml"), false},
+		{bfs.StaticFs(""), "btheme.txt", true},
+		{bfs.StaticFs(""), "ctheme.txt", false},
+	} {
+		_, err := test.fs.Stat(test.filename)
+		assert.Equal(test.exists, err == nil, test.filename)
+	}
+
+	// Project only.
+	bfs, err = NewBase(p, WithOnlyThemes())
+	assert.NoError(err)
+	assert.Len(bfs.AbsThemeDirs, 0)
+	_, err = bfs.Layouts.Fs.Stat("btheme.html")
+	assert.Error(err)
+
+	_, err = NewBase(p, WithOnlyThemes("dtheme"))
+	assert.Error(err)
+}
+
+func TestWithComponents(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mycontent", "c.md"), []byte("content"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mydata", "d.toml"), []byte("a = 1"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", "l.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mystatic", "s.txt"), []byte("static"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "layouts", "t.html"), []byte("layout"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+
+	bfs, err := NewBase(p, WithComponents("content"))
+	assert.NoError(err)
+
+	checkFileContent(bfs.Content.Fs, "c.md", assert, "content")
+	assert.Equal(hugofs.NoOpFs, bfs.Layouts.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.Data.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.I18n.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.Archetypes.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.Assets.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.Resources.Fs)
+	assert.Equal(hugofs.NoOpFs, bfs.StaticFs(""))
+	for _, component := range []string{"content", "data", "static", "resources"} {
+		sfs, found := bfs.ForComponent(component)
+		assert.True(found, component)
+		assert.NotNil(sfs.Fs, component)
+	}
+	assert.NotEqual(hugofs.NoOpFs, bfs.Work.Fs)
+
+	for _, line := range bfs.DescribeMounts() {
+		assert.True(strings.HasPrefix(line, "content:"), line)
+	}
+
+	bfs, err = NewBase(p, WithComponents("data", "layouts"))
+	assert.NoError(err)
+	assert.Equal(hugofs.NoOpFs, bfs.Content.Fs)
+	checkFileContent(bfs.Layouts.Fs, "t.html", assert, "layout")
+	assert.NotEqual(hugofs.NoOpFs, bfs.Data.Fs)
+
+	// The default is to build everything.
+	bfs, err = NewBase(p)
+	assert.NoError(err)
+	checkFileContent(bfs.Layouts.Fs, "l.html", assert, "layout")
+	checkFileContent(bfs.StaticFs(""), "s.txt", assert, "static")
+
+	_, err = NewBase(p, WithComponents("nope"))
+	assert.Error(err)
+}
+
+func TestOrigin(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", []string{"atheme", "btheme"})
+
+	single := filepath.Join("_default", "single.html")
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", single), []byte("project"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "atheme", "layouts", single), []byte("atheme"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "atheme", "layouts", "index.html"), []byte("atheme"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "btheme", "layouts", "index.html"), []byte("btheme"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "btheme", "layouts", "list.html"), []byte("btheme"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		filename string
+		expected string
+	}{
+		{single, ""},
+		{"index.html", "atheme"},
+		{"list.html", "btheme"},
+	} {
+		origin, err := bfs.Origin("layouts", test.filename)
+		assert.NoError(err, test.filename)
+		assert.Equal(test.expected, origin, test.filename)
+	}
+
+	_, err = bfs.Origin("layouts", "nope.html")
+	assert.True(os.IsNotExist(err))
+	_, err = bfs.Origin("nope", single)
+	assert.Error(err)
+}
+
+func TestMakePathRelativeTheme(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mylayouts", "_default", "single.html"), []byte("layout"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "layouts", "partials", "header.html"), []byte("layout"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	assert.Equal([]string{filepath.Join(workingDir, "mylayouts"), filepath.Join(workingDir, "themes", "mytheme", "layouts")}, bfs.Layouts.Dirnames)
+
+	assert.Equal(filepath.FromSlash("/_default/single.html"), bfs.Layouts.MakePathRelative(filepath.Join(workingDir, "mylayouts", "_default", "single.html")))
+	assert.Equal(filepath.FromSlash("/partials/header.html"), bfs.Layouts.MakePathRelative(filepath.Join(workingDir, "themes", "mytheme", "layouts", "partials", "header.html")))
+	assert.Equal("", bfs.Layouts.MakePathRelative(filepath.Join(workingDir, "themes", "mytheme", "static", "s.txt")))
+	// Sibling dir with the same prefix.
+	assert.Equal("", bfs.Layouts.MakePathRelative(filepath.Join(workingDir, "mylayoutsx", "_default", "single.html")))
+	assert.False(bfs.IsLayout(filepath.Join(workingDir, "mylayoutsx", "_default", "single.html")))
+	assert.Equal(filepath.Join(workingDir, "mycontentx", "a.md"), bfs.RelContentDir(filepath.Join(workingDir, "mycontentx", "a.md")))
+	assert.True(bfs.IsLayout(filepath.Join(workingDir, "themes", "mytheme", "layouts", "partials", "header.html")))
+	assert.Equal([]string{filepath.Join(workingDir, "themes", "mytheme", "layouts", "partials")}, bfs.Layouts.RealDirs("partials"))
+}
+
+func TestWorkFs(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "project.toml"), []byte("project"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "theme.toml"), []byte("theme"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	assert.Equal([]string{workingDir, filepath.Join(workingDir, "themes", "mytheme")}, bfs.Work.Dirnames)
+	checkFileContent(bfs.Work.Fs, "project.toml", assert, "project")
+	checkFileContent(bfs.Work.Fs, "theme.toml", assert, "theme")
+
+	projectFilename := filepath.Join(workingDir, "project.toml")
+	themeFilename := filepath.Join(workingDir, "themes", "mytheme", "theme.toml")
+
+	assert.True(bfs.IsWork(projectFilename))
+	assert.True(bfs.IsWork(themeFilename))
+	assert.False(bfs.IsWork(filepath.FromSlash("/other/project.toml")))
+
+	assert.Equal(filepath.FromSlash("/project.toml"), bfs.Work.MakePathRelative(projectFilename))
+	assert.Equal(filepath.FromSlash("/theme.toml"), bfs.Work.MakePathRelative(themeFilename))
+	assert.Equal("", bfs.Work.MakePathRelative(filepath.FromSlash("/other/project.toml")))
+}
+
+func TestStaticFsTheme(t *testing.T) {
+	for _, multihost := range []bool{false, true} {
+		t.Run(fmt.Sprintf("multihost=%t", multihost), func(t *testing.T) {
+			assert := require.New(t)
+			v := createConfig()
+			workingDir := filepath.FromSlash("/my/work")
+			v.Set("workingDir", workingDir)
+			v.Set("themesDir", "themes")
+			v.Set("theme", "mytheme")
+			v.Set("multihost", multihost)
+			v.Set("defaultContentLanguage", "en")
+			en := langs.NewLanguage("en", v)
+			sv := langs.NewLanguage("sv", v)
+			v.Set("languagesSorted", langs.Languages{en, sv})
+
+			fs := hugofs.NewMem(v)
+
+			afero.WriteFile(fs.Source, filepath.Join(workingDir, "mystatic", "project.txt"), []byte("project"), 0755)
+			themeFilename := filepath.Join(workingDir, "themes", "mytheme", "static", "css", "theme.css")
+			afero.WriteFile(fs.Source, themeFilename, []byte("theme"), 0755)
+
+			p, err := paths.New(fs, v)
+			assert.NoError(err)
+			bfs, err := NewBase(p)
+			assert.NoError(err)
+
+			if multihost {
+				assert.Len(bfs.Static, 2)
+			} else {
+				assert.Len(bfs.Static, 1)
+			}
+
+			for _, staticFs := range bfs.Static {
+				assert.Equal([]string{filepath.Join(workingDir, "mystatic"), filepath.Join(workingDir, "themes", "mytheme", "static")}, staticFs.Dirnames)
+				checkFileContent(staticFs.Fs, filepath.Join("css", "theme.css"), assert, "theme")
+			}
+
+			assert.True(bfs.IsStatic(themeFilename))
+			assert.Equal(filepath.FromSlash("/css/theme.css"), bfs.MakeStaticPathRelative(themeFilename))
+			assert.Equal(filepath.FromSlash("/project.txt"), bfs.MakeStaticPathRelative(filepath.Join(workingDir, "mystatic", "project.txt")))
+		})
+	}
+}
+
+func TestStaticPublishPath(t *testing.T) {
+	for _, multihost := range []bool{false, true} {
+		t.Run(fmt.Sprintf("multihost=%t", multihost), func(t *testing.T) {
+			assert := require.New(t)
+			v := createConfig()
+			workingDir := filepath.FromSlash("/my/work")
+			v.Set("workingDir", workingDir)
+			v.Set("multihost", multihost)
+			v.Set("defaultContentLanguage", "en")
+			en := langs.NewLanguage("en", v)
+			sv := langs.NewLanguage("sv", v)
+			v.Set("languagesSorted", langs.Languages{en, sv})
+
+			fs := hugofs.NewMem(v)
+
+			afero.WriteFile(fs.Source, filepath.Join(workingDir, "mystatic", "css", "main.css"), []byte("main"), 0755)
+
+			p, err := paths.New(fs, v)
+			assert.NoError(err)
+			bfs, err := NewBase(p)
+			assert.NoError(err)
+
+			rel := filepath.Join("css", "main.css")
+
+			if multihost {
+				assert.Equal(filepath.FromSlash("/sv/css/main.css"), bfs.StaticPublishPath("sv", rel))
+				assert.Equal(filepath.FromSlash("/en/css/main.css"), bfs.StaticPublishPath("en", rel))
+			} else {
+				assert.Equal(filepath.FromSlash("/css/main.css"), bfs.StaticPublishPath("sv", rel))
+				assert.Equal(filepath.FromSlash("/css/main.css"), bfs.StaticPublishPath("en", rel))
+			}
+		})
+	}
+}
+
+func TestNewSourceFilesystem(t *testing.T) {
+	assert := require.New(t)
+	sourceFs := afero.NewMemMapFs()
+	dir1, dir2 := filepath.FromSlash("/my/dir1"), filepath.FromSlash("/my/dir2")
+
+	afero.WriteFile(sourceFs, filepath.Join(dir1, "sub", "a.txt"), []byte("a"), 0755)
+	afero.WriteFile(sourceFs, filepath.Join(dir2, "b.txt"), []byte("b"), 0755)
+
+	fs, err := createOverlayFs(sourceFs, []string{dir1, dir2})
+	assert.NoError(err)
+
+	sfs := NewSourceFilesystem(fs, sourceFs, []string{dir1, dir2})
+
+	assert.True(sfs.Contains(filepath.Join(dir2, "b.txt")))
+	assert.False(sfs.Contains(filepath.FromSlash("/my/dir3/c.txt")))
+	assert.Equal(filepath.FromSlash("/sub/a.txt"), sfs.MakePathRelative(filepath.Join(dir1, "sub", "a.txt")))
+	assert.Equal("", sfs.MakePathRelative(filepath.FromSlash("/my/dir3/c.txt")))
+	realFilename, found := sfs.RealFilename("b.txt")
+	assert.True(found)
+	assert.Equal(filepath.Join(dir2, "b.txt"), realFilename)
+	realFilename, found = sfs.RealFilename(filepath.Join("sub", "a.txt"))
+	assert.True(found)
+	assert.Equal(filepath.Join(dir1, "sub", "a.txt"), realFilename)
+	realFilename, found = sfs.RealFilename("nope.txt")
+	assert.False(found)
+	assert.Equal("", realFilename)
+	assert.Equal([]string{filepath.Join(dir1, "sub")}, sfs.RealDirs("sub"))
+	checkFileContent(sfs.Fs, filepath.Join("sub", "a.txt"), assert, "a")
+}
+
+func TestContainsSymlinkedDir(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestContainsSymlinkedDir as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+
+	d, clean, err := tempdir.Create(hugofs.Os, "hugo-contains-symlink")
+	assert.NoError(err)
+	defer clean()
+
+	// The temp dir may itself be behind a symlink, e.g. on macOS.
+	d, err = filepath.EvalSymlinks(d)
+	assert.NoError(err)
+
+	realDir := filepath.Join(d, "realcontent")
+	symlinkDir := filepath.Join(d, "work", "content")
+	assert.NoError(os.MkdirAll(realDir, 0755))
+	assert.NoError(os.MkdirAll(filepath.Dir(symlinkDir), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(realDir, "post.md"), []byte("post"), 0755))
+	assert.NoError(os.Symlink(realDir, symlinkDir))
+
+	sfs := NewSourceFilesystem(hugofs.NewNoLstatFs(hugofs.Os), hugofs.Os, []string{symlinkDir})
+
+	assert.True(sfs.Contains(filepath.Join(symlinkDir, "post.md")))
+	assert.True(sfs.Contains(filepath.Join(realDir, "post.md")))
+	// Removed.
+	assert.True(sfs.Contains(filepath.Join(realDir, "removed.md")))
+	assert.False(sfs.Contains(filepath.Join(d, "realcontentx", "post.md")))
+	assert.False(sfs.Contains(filepath.Join(d, "other", "post.md")))
+
+	// A symlink in the filename's directory.
+	aliasDir := filepath.Join(d, "alias")
+	assert.NoError(os.Symlink(d, aliasDir))
+	assert.True(sfs.Contains(filepath.Join(aliasDir, "realcontent", "post.md")))
+	assert.True(sfs.Contains(filepath.Join(aliasDir, "realcontent", "removed.md")))
+	assert.False(sfs.Contains(filepath.Join(aliasDir, "other", "post.md")))
+
+	// Every existing directory is resolved once.
+	assert.Equal(map[string]string{filepath.Join(aliasDir, "realcontent"): realDir}, sfs.resolvedDirs)
+
+	// Only resolved on the OS filesystem.
+	sfs = NewSourceFilesystem(afero.NewMemMapFs(), afero.NewMemMapFs(), []string{symlinkDir})
+	assert.False(sfs.Contains(filepath.Join(realDir, "post.md")))
+}
+
+func TestArchetype(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "myarchetypes", "post.md"), []byte("post"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "archetypes", "default.md"), []byte("default"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		kind     string
+		expected string
+		filename string
+	}{
+		{"post", "post.md", filepath.Join(workingDir, "myarchetypes", "post.md")},
+		{"unknown", "default.md", filepath.Join(workingDir, "themes", "mytheme", "archetypes", "default.md")},
+		{"", "default.md", filepath.Join(workingDir, "themes", "mytheme", "archetypes", "default.md")},
+	} {
+		p, fi, found := bfs.Archetype(test.kind, ".md")
+		assert.True(found, test.kind)
+		assert.Equal(test.expected, p)
+		assert.Equal(test.filename, fi.(hugofs.RealFilenameInfo).RealFilename())
+	}
+
+	_, _, found := bfs.Archetype("post", ".html")
+	assert.False(found)
+}
+
+func TestForComponent(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	v.Set("workingDir", filepath.FromSlash("/my/work"))
+	v.Set("defaultContentLanguage", "sv")
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		name     string
+		expected *SourceFilesystem
+	}{
+		{"content", bfs.Content},
+		{"data", bfs.Data},
+		{"i18n", bfs.I18n},
+		{"layouts", bfs.Layouts},
+		{"archetypes", bfs.Archetypes},
+		{"assets", bfs.Assets},
+		{"resources", bfs.Resources},
+		{"static", bfs.Static[""]},
+	} {
+		sfs, found := bfs.ForComponent(test.name)
+		assert.True(found, test.name)
+		assert.NotNil(sfs, test.name)
+		assert.True(test.expected == sfs, test.name)
+	}
+
+	_, found := bfs.ForComponent("nope")
+	assert.False(found)
+
+	// Multihost.
+	v.Set("multihost", true)
+	sv := langs.NewLanguage("sv", v)
+	en := langs.NewLanguage("en", v)
+	v.Set("languagesSorted", langs.Languages{en, sv})
+	p, err = paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err = NewBase(p)
+	assert.NoError(err)
+
+	sfs, found := bfs.ForComponent("static")
+	assert.True(found)
+	assert.True(bfs.Static["sv"] == sfs)
+}
+
+func TestRealDirs(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewDefault(v)
+	sfs := fs.Source
+
+	root, cleanRoot, err := tempdir.Create(sfs, "realdir")
+	assert.NoError(err)
+	defer cleanRoot()
+	themesDir, cleanThemesDir, err := tempdir.Create(sfs, "themesDir")
+	assert.NoError(err)
+	defer cleanThemesDir()
+
+	v.Set("workingDir", root)
+	v.Set("themesDir", themesDir)
+	v.Set("theme", "mytheme")
+
+	assert.NoError(sfs.MkdirAll(filepath.Join(root, "myassets", "scss", "sf1"), 0755))
+	assert.NoError(sfs.MkdirAll(filepath.Join(root, "myassets", "scss", "sf2"), 0755))
+	assert.NoError(sfs.MkdirAll(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf2"), 0755))
+	assert.NoError(sfs.MkdirAll(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf3"), 0755))
+	assert.NoError(sfs.MkdirAll(filepath.Join(root, "resources"), 0755))
+	assert.NoError(sfs.MkdirAll(filepath.Join(themesDir, "mytheme", "resources"), 0755))
+
+	assert.NoError(sfs.MkdirAll(filepath.Join(root, "myassets", "js", "f2"), 0755))
+
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "scss", "sf1", "a1.scss")), []byte("content"), 0755)
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "scss", "sf2", "a3.scss")), []byte("content"), 0755)
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "scss", "a2.scss")), []byte("content"), 0755)
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf2", "a3.scss")), []byte("content"), 0755)
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf3", "a4.scss")), []byte("content"), 0755)
+
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(themesDir, "mytheme", "resources", "t1.txt")), []byte("content"), 0755)
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "resources", "p1.txt")), []byte("content"), 0755)
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "resources", "p2.txt")), []byte("content"), 0755)
+
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "js", "f2", "a1.js")), []byte("content"), 0755)
+	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "js", "a2.js")), []byte("content"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+	assert.NotNil(bfs)
+	checkFileCount(bfs.Assets.Fs, "", assert, 6)
+
+	realDirs := bfs.Assets.RealDirs("scss")
+	assert.Equal(2, len(realDirs))
+	assert.Equal(filepath.Join(root, "myassets/scss"), realDirs[0])
+	assert.Equal(filepath.Join(themesDir, "mytheme/assets/scss"), realDirs[len(realDirs)-1])
+
+	checkFileCount(bfs.Resources.Fs, "", assert, 3)
+
+	assert.NotNil(bfs.themeFs)
+	fi, b, err := bfs.themeFs.(afero.Lstater).LstatIfPossible(filepath.Join("resources", "t1.txt"))
+	assert.NoError(err)
+	assert.False(b)
+	assert.Equal("t1.txt", fi.Name())
+
+}
+
+func TestStaticFs(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := "mywork"
+	v.Set("workingDir", workDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "t1")
+
+	fs := hugofs.NewMem(v)
+
+	themeStaticDir := filepath.Join(workDir, "themes", "t1", "static")
+
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mystatic", "f1.txt"), []byte("Hugo Rocks!"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f1.txt"), []byte("Hugo Themes Rocks!"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f2.txt"), []byte("Hugo Themes Still Rocks!"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+	sfs := bfs.StaticFs("en")
+	checkFileContent(sfs, "f1.txt", assert, "Hugo Rocks!")
+	checkFileContent(sfs, "f2.txt", assert, "Hugo Themes Still Rocks!")
+
+}
+
+func TestStaticFsMultiHost(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := "mywork"
+	v.Set("workingDir", workDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "t1")
+	v.Set("multihost", true)
+
+	vn := viper.New()
+	vn.Set("staticDir", "nn_static")
+
+	en := langs.NewLanguage("en", v)
+	no := langs.NewLanguage("no", v)
+	no.Set("staticDir", "static_no")
+
+	languages := langs.Languages{
+		en,
+		no,
+	}
+
+	v.Set("languagesSorted", languages)
+
+	fs := hugofs.NewMem(v)
+
+	themeStaticDir := filepath.Join(workDir, "themes", "t1", "static")
+
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mystatic", "f1.txt"), []byte("Hugo Rocks!"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "static_no", "f1.txt"), []byte("Hugo Rocks in Norway!"), 0755)
+
+	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f1.txt"), []byte("Hugo Themes Rocks!"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f2.txt"), []byte("Hugo Themes Still Rocks!"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+	enFs := bfs.StaticFs("en")
+	checkFileContent(enFs, "f1.txt", assert, "Hugo Rocks!")
+	checkFileContent(enFs, "f2.txt", assert, "Hugo Themes Still Rocks!")
+
+	noFs := bfs.StaticFs("no")
+	checkFileContent(noFs, "f1.txt", assert, "Hugo Rocks in Norway!")
+	checkFileContent(noFs, "f2.txt", assert, "Hugo Themes Still Rocks!")
+}
+
+func TestContentFsSharedContentDir(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := "mywork"
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	// Same directory, different spelling.
+	sv.ContentDir = "mycontent/"
+
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{"post.en.md", "post.sv.md", "about.md"} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", "blog", filename), []byte(filename), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+	assert.Len(bfs.Content.Dirnames, 1)
+
+	dir, err := bfs.Content.Fs.Open("blog")
+	assert.NoError(err)
+	fis, err := dir.Readdir(-1)
+	assert.NoError(err)
+	assert.Len(fis, 3)
+
+	fileLangs := make(map[string]string)
+	for _, fi := range fis {
+		lfi := fi.(*hugofs.LanguageFileInfo)
+		_, found := fileLangs[lfi.RealName()]
+		assert.False(found, lfi.RealName())
+		fileLangs[lfi.RealName()] = lfi.Lang()
+	}
+
+	assert.Equal(map[string]string{"post.en.md": "en", "post.sv.md": "sv", "about.md": "en"}, fileLangs)
+}
+
+func TestContentFsOverlappingContentDirs(t *testing.T) {
+	assert := require.New(t)
+	workDir := filepath.FromSlash("/my/work")
+
+	newBase := func(svContentDir string) (*BaseFs, error) {
+		v := createConfig()
+		v.Set("workingDir", workDir)
+		v.Set("defaultContentLanguage", "en")
+
+		en := langs.NewLanguage("en", v)
+		sv := langs.NewLanguage("sv", v)
+		sv.ContentDir = svContentDir
+
+		v.Set("languagesSorted", langs.Languages{en, sv})
+
+		fs := hugofs.NewMem(v)
+		afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", "sv", "post.md"), []byte("post"), 0755)
+
+		p, err := paths.New(fs, v)
+		assert.NoError(err)
+		return NewBase(p)
+	}
+
+	// Same directory, relative and absolute.
+	bfs, err := newBase(filepath.Join(workDir, "mycontent"))
+	assert.NoError(err)
+	assert.Len(bfs.Content.Dirnames, 1)
+
+	// Nested.
+	_, err = newBase(filepath.Join("mycontent", "sv"))
+	assert.Error(err)
+	assert.Contains(err.Error(), `for language "en"`)
+	assert.Contains(err.Error(), `for language "sv"`)
+
+	// Same prefix, but not nested.
+	_, err = newBase("mycontentsv")
+	assert.NoError(err)
+}
+
+func TestContentLang(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	sv.ContentDir = "mycontent_sv"
+	nn := langs.NewLanguage("nn", v)
+
+	v.Set("languagesSorted", langs.Languages{en, sv, nn})
+
+	fs := hugofs.NewMem(v)
+
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", "post.md"), []byte("content"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent_sv", "post.md"), []byte("content"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		filename string
+		expected string
+	}{
+		{filepath.Join(workDir, "mycontent", "post.md"), "en"},
+		{filepath.Join(workDir, "mycontent", "post.nn.md"), "nn"},
+		{filepath.Join(workDir, "mycontent_sv", "post.md"), "sv"},
+		{filepath.Join(workDir, "mycontent_sv", "blog", "post.md"), "sv"},
+		{filepath.Join(workDir, "mycontent_sv", "post.nn.md"), "nn"},
+		{filepath.Join(workDir, "mycontent_sv", "post.fr.md"), "sv"},
+		{filepath.Join("blog", "post.sv.md"), "sv"},
+		{filepath.Join("blog", "post.md"), "en"},
+		{filepath.Join(workDir, "mycontent_svx", "post.md"), "en"},
+	} {
+		assert.Equal(test.expected, bfs.ContentLang(test.filename), test.filename)
+	}
+}
+
+func TestSectionIndex(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{
+		"blog/_index.md",
+		"blog/_index.sv.md",
+		"blog/post.md",
+		"docs/_index.md",
+		"news/post.md",
+	} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", filepath.FromSlash(filename)), []byte("content"), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		section  string
+		lang     string
+		expected string
+	}{
+		{"blog", "sv", "_index.sv.md"},
+		{"blog", "en", "_index.md"},
+		{"docs", "sv", "_index.md"},
+		{"docs", "en", "_index.md"},
+		{"news", "sv", ""},
+		{"news", "en", ""},
+		{"nope", "en", ""},
+	} {
+		fi, found := bfs.SectionIndex(test.section, test.lang)
+		assert.Equal(test.expected != "", found, test.section+":"+test.lang)
+		if found {
+			assert.Equal(test.expected, fi.RealName(), test.section+":"+test.lang)
+			assert.Equal(filepath.Join(workDir, "mycontent", test.section, test.expected), fi.Filename())
+		}
+	}
+}
+
+func TestMetaFor(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{
+		filepath.Join("mycontent", "blog", "post.md"),
+		filepath.Join("mycontent", "blog", "post.sv.md"),
+		filepath.Join("mylayouts", "_default", "single.html"),
+		filepath.Join("mystatic", "logo.png"),
+	} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filename), []byte("content"), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		filename  string
+		component string
+		lang      string
+		path      string
+	}{
+		{filepath.Join("mycontent", "blog", "post.md"), "content", "en", filepath.Join("blog", "post.md")},
+		{filepath.Join("mycontent", "blog", "post.sv.md"), "content", "sv", filepath.Join("blog", "post.sv.md")},
+		{filepath.Join("mylayouts", "_default", "single.html"), "layouts", "sv", filepath.Join("_default", "single.html")},
+		{filepath.Join("mystatic", "logo.png"), "static", "sv", "logo.png"},
+	} {
+		filename := filepath.Join(workDir, test.filename)
+		meta, found := bfs.MetaFor("sv", filename)
+		assert.True(found, test.filename)
+		fi, err := fs.Source.Stat(filename)
+		assert.NoError(err)
+		assert.Equal(FileMeta{Component: test.component, Lang: test.lang, Path: test.path, Filename: filename, ModTime: fi.ModTime()}, meta)
+	}
+
+	for _, filename := range []string{
+		filepath.Join(workDir, "mycontent", "blog", "nope.md"),
+		filepath.Join(workDir, "mycontent", "blog"),
+		filepath.Join(workDir, "other", "post.md"),
+	} {
+		_, found := bfs.MetaFor("sv", filename)
+		assert.False(found, filename)
+	}
+}
+
+func TestClassifyEvents(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{
+		filepath.Join("mycontent", "blog", "post.md"),
+		filepath.Join("mylayouts", "_default", "single.html"),
+		filepath.Join("mystatic", "logo.png"),
+		filepath.Join("themes", "mytheme", "layouts", "index.html"),
+	} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filename), []byte("content"), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	abs := func(filename string) string {
+		return filepath.Join(workDir, filepath.FromSlash(filename))
+	}
+
+	classified := bfs.ClassifyEvents([]string{
+		abs("mycontent/blog/post.md"),
+		abs("mycontent/blog/post.sv.md"),
+		// Duplicate.
+		abs("mycontent/blog/post.md"),
+		// Removed.
+		abs("mycontent/blog/removed.md"),
+		abs("mylayouts/_default/single.html"),
+		abs("themes/mytheme/layouts/index.html"),
+		abs("mystatic/logo.png"),
+		abs("mystatic/logo.png"),
+		abs("other/file.txt"),
+		// Siblings of the component dirs sharing their prefix.
+		abs("mycontentx/blog/post.md"),
+		abs("mylayouts2/_default/single.html"),
+		abs("themes/mytheme/layoutsx/index.html"),
+	})
+
+	assert.Equal(map[string][]ComponentPath{
+		"content": {
+			{Lang: "en", Path: filepath.FromSlash("blog/post.md")},
+			{Lang: "sv", Path: filepath.FromSlash("blog/post.sv.md")},
+			{Lang: "en", Path: filepath.FromSlash("blog/removed.md")},
+		},
+		"layouts": {
+			{Path: filepath.FromSlash("_default/single.html")},
+			{Path: "index.html"},
+		},
+		"static": {
+			{Path: "logo.png"},
+		},
+	}, classified)
+
+	assert.Empty(bfs.ClassifyEvents(nil))
+}
+
+func TestLanguagesFor(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	nn := langs.NewLanguage("nn", v)
+	nn.ContentDir = "mycontent_nn"
+	v.Set("languagesSorted", langs.Languages{en, sv, nn})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{"mycontent/blog/post.sv.md", "mycontent/blog/post.en.md", "mycontent/blog/about.md", "mycontent_nn/blog/post.md"} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filepath.FromSlash(filename)), []byte("content"), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
 
-	root, err := afero.TempDir(sfs, "", "realdir")
+	languages, err := bfs.LanguagesFor(filepath.FromSlash("blog/post"))
 	assert.NoError(err)
-	themesDir, err := afero.TempDir(sfs, "", "themesDir")
-	assert.NoError(err)
-	defer func() {
-		os.RemoveAll(root)
-		os.RemoveAll(themesDir)
-	}()
+	assert.Equal([]string{"en", "nn", "sv"}, languages)
 
-	v.Set("workingDir", root)
-	v.Set("themesDir", themesDir)
-	v.Set("theme", "mytheme")
+	languages, err = bfs.LanguagesFor(filepath.FromSlash("blog/about"))
+	assert.NoError(err)
+	assert.Equal([]string{"en"}, languages)
 
-	assert.NoError(sfs.MkdirAll(filepath.Join(root, "myassets", "scss", "sf1"), 0755))
-	assert.NoError(sfs.MkdirAll(filepath.Join(root, "myassets", "scss", "sf2"), 0755))
-	assert.NoError(sfs.MkdirAll(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf2"), 0755))
-	assert.NoError(sfs.MkdirAll(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf3"), 0755))
-	assert.NoError(sfs.MkdirAll(filepath.Join(root, "resources"), 0755))
-	assert.NoError(sfs.MkdirAll(filepath.Join(themesDir, "mytheme", "resources"), 0755))
+	languages, err = bfs.LanguagesFor(filepath.FromSlash("blog/nope"))
+	assert.NoError(err)
+	assert.Empty(languages)
+}
 
-	assert.NoError(sfs.MkdirAll(filepath.Join(root, "myassets", "js", "f2"), 0755))
+func TestContentFsForLang(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
 
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "scss", "sf1", "a1.scss")), []byte("content"), 0755)
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "scss", "sf2", "a3.scss")), []byte("content"), 0755)
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "scss", "a2.scss")), []byte("content"), 0755)
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf2", "a3.scss")), []byte("content"), 0755)
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(themesDir, "mytheme", "assets", "scss", "sf3", "a4.scss")), []byte("content"), 0755)
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	nn := langs.NewLanguage("nn", v)
+	nn.ContentDir = "mycontent_nn"
+	v.Set("languagesSorted", langs.Languages{en, sv, nn})
 
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(themesDir, "mytheme", "resources", "t1.txt")), []byte("content"), 0755)
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "resources", "p1.txt")), []byte("content"), 0755)
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "resources", "p2.txt")), []byte("content"), 0755)
+	fs := hugofs.NewMem(v)
 
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "js", "f2", "a1.js")), []byte("content"), 0755)
-	afero.WriteFile(sfs, filepath.Join(filepath.Join(root, "myassets", "js", "a2.js")), []byte("content"), 0755)
+	for _, filename := range []string{"mycontent/blog/post.sv.md", "mycontent/blog/post.en.md", "mycontent/blog/about.md", "mycontent/blog/sub/a.sv.md", "mycontent_nn/blog/post.md"} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filepath.FromSlash(filename)), []byte("content"), 0755)
+	}
 
 	p, err := paths.New(fs, v)
 	assert.NoError(err)
 	bfs, err := NewBase(p)
 	assert.NoError(err)
-	assert.NotNil(bfs)
-	checkFileCount(bfs.Assets.Fs, "", assert, 6)
 
-	realDirs := bfs.Assets.RealDirs("scss")
-	assert.Equal(2, len(realDirs))
-	assert.Equal(filepath.Join(root, "myassets/scss"), realDirs[0])
-	assert.Equal(filepath.Join(themesDir, "mytheme/assets/scss"), realDirs[len(realDirs)-1])
+	readdir := func(lang string) []string {
+		fis, err := afero.ReadDir(bfs.ContentFsForLang(lang), "blog")
+		assert.NoError(err)
+		var names []string
+		for _, fi := range fis {
+			if lfi, ok := fi.(*hugofs.LanguageFileInfo); ok && !lfi.IsDir() {
+				names = append(names, lfi.Lang()+":"+lfi.RealName())
+			} else {
+				names = append(names, fi.Name())
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
 
-	checkFileCount(bfs.Resources.Fs, "", assert, 3)
+	assert.Equal([]string{"sub", "sv:post.sv.md"}, readdir("sv"))
+	assert.Equal([]string{"en:about.md", "en:post.en.md", "sub"}, readdir("en"))
+	assert.Equal([]string{"nn:post.md", "sub"}, readdir("nn"))
 
-	assert.NotNil(bfs.themeFs)
-	fi, b, err := bfs.themeFs.(afero.Lstater).LstatIfPossible(filepath.Join("resources", "t1.txt"))
+	svFs := bfs.ContentFsForLang("sv")
+	_, err = svFs.Stat(filepath.FromSlash("blog/post.sv.md"))
 	assert.NoError(err)
-	assert.False(b)
-	assert.Equal("t1.txt", fi.Name())
-
+	_, err = svFs.Stat(filepath.FromSlash("blog/post.en.md"))
+	assert.True(os.IsNotExist(err))
+	_, err = svFs.Open(filepath.FromSlash("blog/about.md"))
+	assert.True(os.IsNotExist(err))
 }
 
-func TestStaticFs(t *testing.T) {
+func TestDataFsLanguage(t *testing.T) {
 	assert := require.New(t)
 	v := createConfig()
-	workDir := "mywork"
+	workDir := filepath.FromSlash("/my/work")
 	v.Set("workingDir", workDir)
-	v.Set("themesDir", "themes")
-	v.Set("theme", "t1")
+	v.Set("defaultContentLanguage", "en")
 
-	fs := hugofs.NewMem(v)
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	v.Set("languagesSorted", langs.Languages{en, sv})
 
-	themeStaticDir := filepath.Join(workDir, "themes", "t1", "static")
+	fs := hugofs.NewMem(v)
 
-	afero.WriteFile(fs.Source, filepath.Join(workDir, "mystatic", "f1.txt"), []byte("Hugo Rocks!"), 0755)
-	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f1.txt"), []byte("Hugo Themes Rocks!"), 0755)
-	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f2.txt"), []byte("Hugo Themes Still Rocks!"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mydata", "menu.yaml"), []byte("menu"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mydata", "menu.sv.yaml"), []byte("menu sv"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mydata", "authors.yaml"), []byte("authors"), 0755)
 
 	p, err := paths.New(fs, v)
 	assert.NoError(err)
 	bfs, err := NewBase(p)
 	assert.NoError(err)
-	sfs := bfs.StaticFs("en")
-	checkFileContent(sfs, "f1.txt", assert, "Hugo Rocks!")
-	checkFileContent(sfs, "f2.txt", assert, "Hugo Themes Still Rocks!")
 
+	for _, test := range []struct {
+		lang     string
+		expected string
+	}{
+		{"sv", "menu sv"},
+		{"en", "menu"},
+	} {
+		dataFs := bfs.DataFs(test.lang)
+		filename := filepath.Join(projectVirtualFolder, "menu.yaml")
+		checkFileContent(dataFs, filename, assert, test.expected)
+		checkFileContent(dataFs, filepath.Join(projectVirtualFolder, "authors.yaml"), assert, "authors")
+		checkFileCount(dataFs, "", assert, 2)
+	}
+
+	// The default view is not language aware.
+	checkFileCount(bfs.Data.Fs, "", assert, 3)
 }
 
-func TestStaticFsMultiHost(t *testing.T) {
+func TestGlobResources(t *testing.T) {
 	assert := require.New(t)
 	v := createConfig()
 	workDir := "mywork"
 	v.Set("workingDir", workDir)
-	v.Set("themesDir", "themes")
-	v.Set("theme", "t1")
-	v.Set("multihost", true)
 
-	vn := viper.New()
-	vn.Set("staticDir", "nn_static")
+	fs := hugofs.NewMem(v)
 
-	en := langs.NewLanguage("en", v)
-	no := langs.NewLanguage("no", v)
-	no.Set("staticDir", "static_no")
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mystatic", "images", "a.png"), []byte("static"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "myassets", "images", "a.png"), []byte("assets"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "myassets", "images", "sub", "b.png"), []byte("assets"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "myassets", "images", "c.jpg"), []byte("assets"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", "images", "d.png"), []byte("content"), 0755)
 
-	languages := langs.Languages{
-		en,
-		no,
-	}
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
 
-	v.Set("languagesSorted", languages)
+	fis, err := bfs.GlobResources("en", "images/**.png")
+	assert.NoError(err)
+	assert.Len(fis, 3)
 
-	fs := hugofs.NewMem(v)
+	assert.Equal("images/a.png", fis[0].Path)
+	assert.Equal(bfs.StaticFs("en"), fis[0].Fs)
+	checkFileContent(fis[0].Fs, "images/a.png", assert, "static")
+	assert.Equal(filepath.FromSlash("mywork/mystatic/images/a.png"), fis[0].FileInfo.(hugofs.RealFilenameInfo).RealFilename())
 
-	themeStaticDir := filepath.Join(workDir, "themes", "t1", "static")
+	assert.Equal("images/sub/b.png", fis[1].Path)
+	assert.Equal(bfs.Assets.Fs, fis[1].Fs)
 
-	afero.WriteFile(fs.Source, filepath.Join(workDir, "mystatic", "f1.txt"), []byte("Hugo Rocks!"), 0755)
-	afero.WriteFile(fs.Source, filepath.Join(workDir, "static_no", "f1.txt"), []byte("Hugo Rocks in Norway!"), 0755)
+	assert.Equal("images/d.png", fis[2].Path)
+	assert.Equal(bfs.Content.Fs, fis[2].Fs)
 
-	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f1.txt"), []byte("Hugo Themes Rocks!"), 0755)
-	afero.WriteFile(fs.Source, filepath.Join(themeStaticDir, "f2.txt"), []byte("Hugo Themes Still Rocks!"), 0755)
+	fis, err = bfs.GlobResources("en", "images/*.jpg")
+	assert.NoError(err)
+	assert.Len(fis, 1)
+	assert.Equal("images/c.jpg", fis[0].Path)
+
+	_, err = bfs.GlobResources("en", "images/[")
+	assert.Error(err)
+}
+
+func TestResolveWithFallback(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	sv.ContentDir = "mycontent_sv"
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{
+		"mycontent/blog/about.md",
+		"mycontent/blog/both.md",
+		"mycontent_sv/blog/both.md",
+		"mycontent_sv/blog/page.md",
+		"myassets/css/main.css",
+		"mystatic/logo.png",
+	} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filepath.FromSlash(filename)), []byte(filename), 0755)
+	}
 
 	p, err := paths.New(fs, v)
 	assert.NoError(err)
 	bfs, err := NewBase(p)
 	assert.NoError(err)
-	enFs := bfs.StaticFs("en")
-	checkFileContent(enFs, "f1.txt", assert, "Hugo Rocks!")
-	checkFileContent(enFs, "f2.txt", assert, "Hugo Themes Still Rocks!")
 
-	noFs := bfs.StaticFs("no")
-	checkFileContent(noFs, "f1.txt", assert, "Hugo Rocks in Norway!")
-	checkFileContent(noFs, "f2.txt", assert, "Hugo Themes Still Rocks!")
+	svFirst := []string{"sv", "en"}
+
+	for _, test := range []struct {
+		languages []string
+		filename  string
+		component string
+		lang      string
+		realName  string
+	}{
+		// Only in the default language.
+		{svFirst, "blog/about.md", "content", "en", "mycontent/blog/about.md"},
+		{svFirst, "blog/page.md", "content", "sv", "mycontent_sv/blog/page.md"},
+		{svFirst, "blog/both.md", "content", "sv", "mycontent_sv/blog/both.md"},
+		{[]string{"en"}, "blog/both.md", "content", "en", "mycontent/blog/both.md"},
+		{svFirst, "css/main.css", "assets", "sv", "myassets/css/main.css"},
+		{svFirst, "logo.png", "static", "sv", "mystatic/logo.png"},
+	} {
+		filename := filepath.FromSlash(test.filename)
+		meta, rfs, err := bfs.ResolveWithFallback(test.languages, filename)
+		assert.NoError(err, test.filename)
+		realFilename := filepath.Join(workDir, filepath.FromSlash(test.realName))
+		realFi, err := fs.Source.Stat(realFilename)
+		assert.NoError(err)
+		assert.Equal(FileMeta{Component: test.component, Lang: test.lang, Path: filename, Filename: realFilename, ModTime: realFi.ModTime()}, meta, test.filename)
+		b, err := afero.ReadFile(fs.Source, meta.Filename)
+		assert.NoError(err)
+		assert.Equal(test.realName, string(b))
+		b, err = afero.ReadFile(rfs, meta.Path)
+		assert.NoError(err, test.filename)
+		assert.Equal(test.realName, string(b), test.filename)
+		fi, err := rfs.Stat(meta.Path)
+		assert.NoError(err)
+		assert.False(fi.IsDir())
+	}
+
+	for _, test := range []struct {
+		languages []string
+		filename  string
+	}{
+		{[]string{"en"}, "blog/page.md"},
+		{svFirst, "blog/nope.md"},
+		{svFirst, "blog"},
+		{nil, "logo.png"},
+	} {
+		_, _, err := bfs.ResolveWithFallback(test.languages, filepath.FromSlash(test.filename))
+		assert.True(os.IsNotExist(err), test.filename)
+	}
 }
 
 func checkFileCount(fs afero.Fs, dirname string, assert *require.Assertions, expected int) {
@@ -361,3 +1838,349 @@ func setConfigAndWriteSomeFilesTo(fs afero.Fs, v *viper.Viper, key, val string,
 		afero.WriteFile(fs, filename, []byte(fmt.Sprintf("content:%s:%d", key, i+1)), 0755)
 	}
 }
+
+func TestDiffContent(t *testing.T) {
+	assert := require.New(t)
+	workDir := filepath.FromSlash("/my/work")
+	fs := hugofs.NewMem(createConfig())
+
+	for _, filename := range []string{
+		"mycontent/blog/a.md",
+		"mycontent/blog/b.md",
+		"mycontent2/blog/a.md",
+		"mycontent2/blog/c.md",
+	} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filepath.FromSlash(filename)), []byte(filename), 0755)
+	}
+
+	newBase := func(contentDir string) *BaseFs {
+		v := createConfig()
+		v.Set("workingDir", workDir)
+		v.Set("contentDir", contentDir)
+		p, err := paths.New(fs, v)
+		assert.NoError(err)
+		bfs, err := NewBase(p)
+		assert.NoError(err)
+		return bfs
+	}
+
+	a, b := newBase("mycontent"), newBase("mycontent2")
+
+	added, removed, shadowed := DiffContent(a, b)
+	assert.Equal([]string{filepath.FromSlash("blog/c.md")}, added)
+	assert.Equal([]string{filepath.FromSlash("blog/b.md")}, removed)
+	assert.Equal([]string{filepath.FromSlash("blog/a.md")}, shadowed)
+
+	added, removed, shadowed = DiffContent(a, a)
+	assert.Empty(added)
+	assert.Empty(removed)
+	assert.Empty(shadowed)
+}
+
+func TestContentFsLanguageErrors(t *testing.T) {
+	assert := require.New(t)
+
+	for _, test := range []struct {
+		name      string
+		languages langs.Languages
+		expect    string
+	}{
+		{"no lang", langs.Languages{{Lang: "en", ContentDir: "mycontent"}, {ContentDir: "mycontent_sv"}}, `no language set for content dir "/my/work/mycontent_sv"`},
+		{"no content dir", langs.Languages{{Lang: "en", ContentDir: "mycontent"}, {Lang: "sv"}}, `no content dir set for language "sv" and no defaultContentLanguage to fall back to`},
+	} {
+		v := createConfig()
+		v.Set("workingDir", "/my/work")
+		v.Set("defaultContentLanguage", "")
+		for _, l := range test.languages {
+			l.Cfg = v
+		}
+		v.Set("languagesSorted", test.languages)
+
+		p, err := paths.New(hugofs.NewMem(v), v)
+		assert.NoError(err)
+		_, err = NewBase(p)
+		assert.Error(err, test.name)
+		assert.Contains(filepath.ToSlash(err.Error()), test.expect, test.name)
+	}
+}
+
+func TestListContent(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	sv.ContentDir = "mycontent_sv"
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{
+		"mycontent/about.md",
+		"mycontent/blog/post.md",
+		"mycontent_sv/blog/post.md",
+		"mycontent_sv/blog/page.md",
+	} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filepath.FromSlash(filename)), []byte(filename), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	files, err := bfs.ListContent()
+	assert.NoError(err)
+
+	var got []string
+	for _, f := range files {
+		rel, err := filepath.Rel(workDir, f.Filename)
+		assert.NoError(err)
+		got = append(got, f.Lang+":"+filepath.ToSlash(f.Path)+":"+filepath.ToSlash(rel))
+	}
+
+	assert.Equal([]string{
+		"en:about.md:mycontent/about.md",
+		"sv:blog/page.md:mycontent_sv/blog/page.md",
+		"en:blog/post.md:mycontent/blog/post.md",
+		"sv:blog/post.md:mycontent_sv/blog/post.md",
+	}, got)
+}
+
+func TestWithReadOnly(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	fs := hugofs.NewMem(v)
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+
+	resourcesDir := filepath.Join(workDir, "resources")
+	afero.WriteFile(fs.Source, filepath.Join(resourcesDir, "a.txt"), []byte("a"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+	assert.True(bfs.Resources.Writable())
+	assert.NoError(afero.WriteFile(bfs.Resources.Fs, "b.txt", []byte("b"), 0755))
+
+	bfs, err = NewBase(p, WithReadOnly())
+	assert.NoError(err)
+	assert.False(bfs.Resources.Writable())
+	_, err = bfs.Resources.Fs.Create("c.txt")
+	assert.Error(err)
+	checkFileContent(bfs.Resources.Fs, "a.txt", assert, "a")
+
+	_, err = fs.Source.Stat(filepath.Join(resourcesDir, "c.txt"))
+	assert.True(os.IsNotExist(err))
+
+	// Missing dirs are not created.
+	v.Set("resourceDir", "myresources")
+	p, err = paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err = NewBase(p, WithReadOnly())
+	assert.NoError(err)
+	_, err = bfs.Resources.Fs.Create("c.txt")
+	assert.Error(err)
+	_, err = fs.Source.Stat(filepath.Join(workDir, "myresources"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestFileMetaCacheKey(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+
+	for _, filename := range []string{
+		"mycontent/blog/post.md",
+		"mycontent/blog/post.sv.md",
+	} {
+		afero.WriteFile(fs.Source, filepath.Join(workDir, filepath.FromSlash(filename)), []byte(filename), 0755)
+	}
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	post := filepath.Join(workDir, "mycontent", "blog", "post.md")
+
+	m1, found := bfs.MetaFor("en", post)
+	assert.True(found)
+	m2, _, err := bfs.ResolveWithFallback([]string{"en"}, filepath.Join("blog", "post.md"))
+	assert.NoError(err)
+	assert.True(m1.Equal(m2))
+	assert.Equal(m1.CacheKey(), m2.CacheKey())
+
+	// The path is given by the filename.
+	m3 := m1
+	m3.Path = "post.md"
+	assert.True(m1.Equal(m3))
+	assert.Equal(m1.CacheKey(), m3.CacheKey())
+
+	m4, found := bfs.MetaFor("en", filepath.Join(workDir, "mycontent", "blog", "post.sv.md"))
+	assert.True(found)
+	assert.False(m1.Equal(m4))
+	assert.NotEqual(m1.CacheKey(), m4.CacheKey())
+
+	m5 := m1
+	m5.Lang = "sv"
+	assert.False(m1.Equal(m5))
+	assert.NotEqual(m1.CacheKey(), m5.CacheKey())
+
+	// A modified file is a new file.
+	assert.False(m1.ModTime.IsZero())
+	m6 := m1
+	m6.ModTime = m1.ModTime.Add(time.Second)
+	assert.False(m1.Equal(m6))
+	assert.NotEqual(m1.CacheKey(), m6.CacheKey())
+
+	// The separator in the key cannot be faked.
+	assert.NotEqual(
+		FileMeta{Component: "content", Lang: "en|x", Filename: "post.md"}.CacheKey(),
+		FileMeta{Component: "content", Lang: "en", Filename: "x|post.md"}.CacheKey())
+}
+
+func TestWithExtraMounts(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workDir)
+	v.Set("defaultContentLanguage", "en")
+
+	en := langs.NewLanguage("en", v)
+	sv := langs.NewLanguage("sv", v)
+	v.Set("languagesSorted", langs.Languages{en, sv})
+
+	fs := hugofs.NewMem(v)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", "blog", "post.md"), []byte("post"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", "docs", "intro.md"), []byte("intro"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	generated := afero.NewMemMapFs()
+	genDir := filepath.FromSlash("/generated")
+	afero.WriteFile(generated, filepath.Join(genDir, "en", "docs", "api.md"), []byte("api"), 0755)
+	afero.WriteFile(generated, filepath.Join(genDir, "en", "docs", "intro.md"), []byte("generated intro"), 0755)
+	afero.WriteFile(generated, filepath.Join(genDir, "sv", "docs", "api.md"), []byte("api sv"), 0755)
+
+	ebfs, err := bfs.WithExtraMounts([]ContentMount{
+		{Dir: filepath.Join(genDir, "en"), Lang: "en"},
+		{Dir: filepath.Join(genDir, "sv"), Lang: "sv"},
+	}, generated)
+	assert.NoError(err)
+
+	checkFileContent(ebfs.Content.Fs, filepath.Join("blog", "post.md"), assert, "post")
+	checkFileContent(ebfs.Content.Fs, filepath.Join("docs", "intro.md"), assert, "generated intro")
+
+	files, err := ebfs.ListContent()
+	assert.NoError(err)
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Lang+":"+filepath.ToSlash(f.Path))
+	}
+	assert.Equal([]string{"en:blog/post.md", "en:docs/api.md", "sv:docs/api.md", "en:docs/intro.md"}, got)
+
+	apiSv := filepath.Join(genDir, "sv", "docs", "api.md")
+	assert.Equal("sv", ebfs.ContentLang(apiSv))
+	assert.True(ebfs.Content.Contains(apiSv))
+	assert.Equal([]string{filepath.Join(genDir, "en"), filepath.Join(genDir, "sv")}, ebfs.Content.Dirnames[:2])
+
+	f, err := ebfs.Content.SourceFs.OpenFile(apiSv, os.O_RDONLY, 0)
+	assert.NoError(err)
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	assert.NoError(err)
+	assert.Equal("api sv", string(b))
+	f, err = ebfs.Content.SourceFs.OpenFile(filepath.Join(workDir, "mycontent", "blog", "post.md"), os.O_RDONLY, 0)
+	assert.NoError(err)
+	f.Close()
+	_, err = ebfs.Content.SourceFs.Stat(filepath.Join(genDir, "svx"))
+	assert.True(os.IsNotExist(err))
+
+	meta, found := ebfs.MetaFor("en", apiSv)
+	assert.True(found)
+	assert.Equal(FileMeta{Component: "content", Lang: "sv", Path: filepath.Join("docs", "api.md"), Filename: apiSv, ModTime: meta.ModTime}, meta)
+	assert.False(meta.ModTime.IsZero())
+	meta, found = ebfs.MetaFor("en", filepath.Join(workDir, "mycontent", "blog", "post.md"))
+	assert.True(found)
+	assert.Equal("en", meta.Lang)
+
+	assert.Equal([]string{
+		filepath.Join(genDir, "en", "docs"),
+		filepath.Join(genDir, "sv", "docs"),
+		filepath.Join(workDir, "mycontent", "docs"),
+	}, ebfs.Content.RealDirs("docs"))
+
+	// The original is unchanged.
+	_, err = bfs.Content.Fs.Stat(filepath.Join("docs", "api.md"))
+	assert.True(os.IsNotExist(err))
+	checkFileContent(bfs.Content.Fs, filepath.Join("docs", "intro.md"), assert, "intro")
+	assert.False(bfs.Content.Contains(apiSv))
+	assert.Len(bfs.Content.Dirnames, 1)
+	_, found = bfs.MetaFor("en", apiSv)
+	assert.False(found)
+	assert.Equal([]string{filepath.Join(workDir, "mycontent", "docs")}, bfs.Content.RealDirs("docs"))
+
+	_, err = bfs.WithExtraMounts([]ContentMount{{Dir: genDir, Lang: "de"}}, generated)
+	assert.Error(err)
+	_, err = bfs.WithExtraMounts([]ContentMount{{Dir: "generated", Lang: "en"}}, generated)
+	assert.Error(err)
+}
+
+func TestI18nAndDataShadowedFile(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	workingDir := filepath.FromSlash("/my/work")
+	v.Set("workingDir", workingDir)
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	fs := hugofs.NewMem(v)
+
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "myi18n", "en.toml"), []byte("hello = \"project\"\n"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "i18n", "en.toml"), []byte("hello = \"theme\"\nbye = \"theme\"\n"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "mydata", "en.toml"), []byte("hello = \"project\"\n"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workingDir, "themes", "mytheme", "data", "en.toml"), []byte("hello = \"theme\"\n"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	for _, sfs := range []*SourceFilesystem{bfs.I18n, bfs.Data} {
+		var filenames []string
+		assert.NoError(afero.Walk(sfs.Fs, "", func(path string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() {
+				filenames = append(filenames, path)
+			}
+			return err
+		}))
+		assert.Equal([]string{filepath.Join(projectVirtualFolder, "en.toml")}, filenames)
+
+		_, err = sfs.Fs.Stat(filepath.Join("mytheme", "en.toml"))
+		assert.True(os.IsNotExist(err))
+	}
+
+	// The project's file as is, nothing merged from the theme's.
+	checkFileContent(bfs.I18n.Fs, filepath.Join(projectVirtualFolder, "en.toml"), assert, "hello = \"project\"\n")
+	checkFileContent(bfs.Data.Fs, filepath.Join(projectVirtualFolder, "en.toml"), assert, "hello = \"project\"\n")
+	b, err := afero.ReadFile(bfs.I18n.Fs, filepath.Join(projectVirtualFolder, "en.toml"))
+	assert.NoError(err)
+	assert.NotContains(string(b), "bye")
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceFilesystemForwardSlashes(t *testing.T) {
+	assert := require.New(t)
+
+	sfs := &SourceFilesystem{
+		SourceFs: afero.NewMemMapFs(),
+		Dirnames: []string{`C:\my\work\mycontent\`, `C:\my\work\themes\mytheme\content\`},
+	}
+
+	assert.True(sfs.Contains(`C:\my\work\mycontent\blog\post.md`))
+	assert.True(sfs.Contains("C:/my/work/mycontent/blog/post.md"))
+	assert.True(sfs.Contains("C:/my/work/themes/mytheme/content/about.md"))
+	assert.False(sfs.Contains("C:/my/work/mystatic/logo.png"))
+
+	assert.Equal(`blog\post.md`, sfs.MakePathRelative("C:/my/work/mycontent/blog/post.md"))
+	assert.Equal("about.md", sfs.MakePathRelative("C:/my/work/themes/mytheme/content/about.md"))
+	assert.Equal("", sfs.MakePathRelative("C:/my/work/mystatic/logo.png"))
+}
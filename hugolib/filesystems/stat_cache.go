@@ -0,0 +1,93 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"os"
+	"sync"
+
+	"github.com/gohugoio/hugo/hugofs"
+	"github.com/spf13/afero"
+)
+
+var _ hugofs.Reseter = (*ResourceStatCache)(nil)
+
+// ResourceStatCache memoizes the result of SourceFilesystems.StatResource.
+// Both hits and misses (os.IsNotExist errors) are cached. Any other error is
+// returned as is and not cached.
+// It is safe for concurrent use.
+type ResourceStatCache struct {
+	sfs *SourceFilesystems
+
+	mu    sync.RWMutex
+	cache map[resourceStatKey]resourceStatResult
+}
+
+type resourceStatKey struct {
+	lang     string
+	filename string
+}
+
+type resourceStatResult struct {
+	fi  os.FileInfo
+	fs  afero.Fs
+	err error
+}
+
+// NewResourceStatCache creates a new ResourceStatCache for the given filesystems.
+func NewResourceStatCache(sfs *SourceFilesystems) *ResourceStatCache {
+	return &ResourceStatCache{sfs: sfs, cache: make(map[resourceStatKey]resourceStatResult)}
+}
+
+// StatResource is a cached version of SourceFilesystems.StatResource.
+func (c *ResourceStatCache) StatResource(lang, filename string) (os.FileInfo, afero.Fs, error) {
+	key := resourceStatKey{lang: lang, filename: filename}
+
+	c.mu.RLock()
+	r, found := c.cache[key]
+	c.mu.RUnlock()
+	if found {
+		return r.fi, r.fs, r.err
+	}
+
+	fi, fs, err := c.sfs.StatResource(lang, filename)
+	if err == nil || os.IsNotExist(err) {
+		c.mu.Lock()
+		c.cache[key] = resourceStatResult{fi: fi, fs: fs, err: err}
+		c.mu.Unlock()
+	}
+
+	return fi, fs, err
+}
+
+// Invalidate removes any cached result for the given filename in all
+// languages. The filename is relative, as passed to StatResource.
+func (c *ResourceStatCache) Invalidate(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k := range c.cache {
+		if k.filename == filename {
+			delete(c.cache, k)
+		}
+	}
+}
+
+// Reset clears the cache.
+func (c *ResourceStatCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache = make(map[resourceStatKey]resourceStatResult)
+}
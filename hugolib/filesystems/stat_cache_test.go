@@ -0,0 +1,168 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gohugoio/hugo/hugofs"
+	"github.com/gohugoio/hugo/hugolib/paths"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type statCountingFs struct {
+	afero.Fs
+	count int64
+}
+
+func (fs *statCountingFs) Stat(name string) (os.FileInfo, error) {
+	atomic.AddInt64(&fs.count, 1)
+	return fs.Fs.Stat(name)
+}
+
+func (fs *statCountingFs) reset() {
+	atomic.StoreInt64(&fs.count, 0)
+}
+
+func (fs *statCountingFs) stats() int64 {
+	return atomic.LoadInt64(&fs.count)
+}
+
+func newStatCountingBaseFs(assert *require.Assertions) (*BaseFs, *statCountingFs) {
+	v := createConfig()
+	workDir := "mywork"
+	v.Set("workingDir", workDir)
+
+	cfs := &statCountingFs{Fs: afero.NewMemMapFs()}
+	fs := hugofs.NewFrom(cfs, v)
+
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mystatic", "s.txt"), []byte("static"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "myassets", "a.txt"), []byte("assets"), 0755)
+	afero.WriteFile(fs.Source, filepath.Join(workDir, "mycontent", "c.txt"), []byte("content"), 0755)
+
+	p, err := paths.New(fs, v)
+	assert.NoError(err)
+	bfs, err := NewBase(p)
+	assert.NoError(err)
+
+	cfs.reset()
+
+	return bfs, cfs
+}
+
+func TestResourceStatCache(t *testing.T) {
+	assert := require.New(t)
+	bfs, cfs := newStatCountingBaseFs(assert)
+
+	c := NewResourceStatCache(bfs.SourceFilesystems)
+
+	fi, fs, err := c.StatResource("en", "a.txt")
+	assert.NoError(err)
+	assert.Equal("a.txt", fi.Name())
+	assert.Equal(bfs.Assets.Fs, fs)
+
+	stats := cfs.stats()
+	assert.True(stats > 0)
+
+	for i := 0; i < 3; i++ {
+		fi, fs, err = c.StatResource("en", "a.txt")
+		assert.NoError(err)
+		assert.Equal("a.txt", fi.Name())
+		assert.Equal(bfs.Assets.Fs, fs)
+	}
+	assert.Equal(stats, cfs.stats())
+
+	// Misses are also cached.
+	_, _, err = c.StatResource("en", "nope.txt")
+	assert.True(os.IsNotExist(err))
+	stats = cfs.stats()
+	_, _, err = c.StatResource("en", "nope.txt")
+	assert.True(os.IsNotExist(err))
+	assert.Equal(stats, cfs.stats())
+
+	// Create the missing file and invalidate.
+	afero.WriteFile(bfs.Content.SourceFs, filepath.Join("mywork", "mycontent", "nope.txt"), []byte("content"), 0755)
+	_, _, err = c.StatResource("en", "nope.txt")
+	assert.True(os.IsNotExist(err))
+	c.Invalidate("nope.txt")
+	fi, fs, err = c.StatResource("en", "nope.txt")
+	assert.NoError(err)
+	assert.Equal("nope.txt", fi.(hugofs.FilePather).Path())
+	assert.Equal(bfs.Content.Fs, fs)
+
+	stats = cfs.stats()
+	c.Reset()
+	_, _, err = c.StatResource("en", "a.txt")
+	assert.NoError(err)
+	assert.True(cfs.stats() > stats)
+}
+
+func TestResourceStatCacheConcurrent(t *testing.T) {
+	assert := require.New(t)
+	bfs, _ := newStatCountingBaseFs(assert)
+
+	c := NewResourceStatCache(bfs.SourceFilesystems)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for _, filename := range []string{"s.txt", "a.txt", "c.txt", "nope.txt"} {
+					c.StatResource("en", filename)
+				}
+				if j%10 == 0 {
+					c.Invalidate("a.txt")
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func BenchmarkResourceStatCache(b *testing.B) {
+	assert := require.New(b)
+	filenames := []string{"s.txt", "a.txt", "c.txt", "nope.txt"}
+
+	b.Run("Uncached", func(b *testing.B) {
+		bfs, cfs := newStatCountingBaseFs(assert)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, filename := range filenames {
+				bfs.StatResource("en", filename)
+			}
+		}
+		b.ReportMetric(float64(cfs.stats())/float64(b.N), "stats/op")
+	})
+
+	b.Run("Cached", func(b *testing.B) {
+		bfs, cfs := newStatCountingBaseFs(assert)
+		c := NewResourceStatCache(bfs.SourceFilesystems)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, filename := range filenames {
+				c.StatResource("en", filename)
+			}
+		}
+		b.ReportMetric(float64(cfs.stats())/float64(b.N), "stats/op")
+	})
+}
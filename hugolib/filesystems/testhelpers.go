@@ -0,0 +1,68 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gohugoio/hugo/config"
+	"github.com/gohugoio/hugo/hugofs"
+	"github.com/gohugoio/hugo/hugolib/paths"
+	"github.com/spf13/afero"
+)
+
+// The config keys holding the project directory of each component.
+var componentDirKeys = map[string]string{
+	"content":    "contentDir",
+	"data":       "dataDir",
+	"i18n":       "i18nDir",
+	"layouts":    "layoutDir",
+	"static":     "staticDir",
+	"archetypes": "archetypeDir",
+	"assets":     "assetDir",
+}
+
+// NewTestBaseFs creates a new BaseFs on top of an in-memory filesystem seeded
+// with the given files, filename mapped to content, used in tests.
+// The filenames are relative to the working directory, with '/' as separator.
+// The first path element of a filename naming a component, e.g. "content" in
+// "content/post.md", is replaced with the directory configured for it, so the
+// file will end up in that component. Other filenames, e.g. those below
+// "themes", are written as is.
+func NewTestBaseFs(cfg config.Provider, files map[string]string) (*BaseFs, error) {
+	fs := hugofs.NewMem(cfg)
+	workingDir := cfg.GetString("workingDir")
+
+	for name, content := range files {
+		filename := filepath.FromSlash(name)
+		parts := strings.SplitN(filename, filePathSeparator, 2)
+		if key, found := componentDirKeys[parts[0]]; found && len(parts) == 2 {
+			if dirs := config.GetStringSlicePreserveString(cfg, key); len(dirs) > 0 {
+				filename = filepath.Join(dirs[0], parts[1])
+			}
+		}
+
+		if err := afero.WriteFile(fs.Source, paths.AbsPathify(workingDir, filename), []byte(content), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := paths.New(fs, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBase(p)
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gohugoio/hugo/hugofs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTestBaseFs(t *testing.T) {
+	assert := require.New(t)
+	v := createConfig()
+	v.Set("workingDir", filepath.FromSlash("/my/work"))
+	v.Set("themesDir", "themes")
+	v.Set("theme", "mytheme")
+
+	bfs, err := NewTestBaseFs(v, map[string]string{
+		"content/post.md":                      "content",
+		"layouts/_default/single.html":         "layout",
+		"static/s.txt":                         "static",
+		"themes/mytheme/layouts/index.html":    "theme layout",
+		"themes/mytheme/static/theme/s.txt":    "theme static",
+		"themes/mytheme/archetypes/default.md": "archetype",
+	})
+	assert.NoError(err)
+
+	fi, err := bfs.Content.Fs.Stat("post.md")
+	assert.NoError(err)
+	lfi := fi.(*hugofs.LanguageFileInfo)
+	assert.Equal("post.md", lfi.RealName())
+	assert.Equal("en", lfi.Lang())
+	assert.Equal(filepath.FromSlash("/my/work/mycontent/post.md"), lfi.Filename())
+	assert.Equal(int64(len("content")), fi.Size())
+
+	checkFileContent(bfs.Content.Fs, "post.md", assert, "content")
+	checkFileContent(bfs.Layouts.Fs, filepath.Join("_default", "single.html"), assert, "layout")
+	checkFileContent(bfs.Layouts.Fs, "index.html", assert, "theme layout")
+	checkFileContent(bfs.StaticFs(""), "s.txt", assert, "static")
+	checkFileContent(bfs.StaticFs(""), filepath.Join("theme", "s.txt"), assert, "theme static")
+	checkFileContent(bfs.Archetypes.Fs, "default.md", assert, "archetype")
+}
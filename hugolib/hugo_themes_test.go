@@ -96,16 +96,18 @@ v2 = "v24"
 				// Check data
 				// theme3 should win the offset competition
 				b.AssertFileContent("public/index.html", "theme1o::[offset][v]theme3", "theme4o::[offset][v]theme3", "themeStandaloneo::[offset][v]theme3")
-				b.AssertFileContent("public/index.html", "nproject::[inner][other]project|[project][other]project|[theme][other]theme4|[theme1][other]theme1")
-				b.AssertFileContent("public/index.html", "ntheme::[inner][other]theme4|[theme][other]theme4|[theme1][other]theme1|[theme2][other]theme2|[theme3][other]theme3")
-				b.AssertFileContent("public/index.html", "theme1::[inner][other]project|[project][other]project|[theme][other]theme1|[theme1][other]theme1|")
-				b.AssertFileContent("public/index.html", "theme4::[inner][other]project|[project][other]project|[theme][other]theme4|[theme4][other]theme4|")
+				// A data file in the project or a theme hides the same file in
+				// the themes after it.
+				b.AssertFileContent("public/index.html", "nproject::[inner][other]project|[project][other]project|\n")
+				b.AssertFileContent("public/index.html", "ntheme::[inner][other]theme4|[theme][other]theme4|[theme4][other]theme4|\n")
+				b.AssertFileContent("public/index.html", "theme1::[inner][other]project|[project][other]project|\n")
+				b.AssertFileContent("public/index.html", "theme4::[inner][other]project|[project][other]project|\n")
 
 				// Check layouts
 				b.AssertFileContent("public/index.html", "partial ntheme: theme4", "partial theme2o: theme3")
 
-				// Check i18n
-				b.AssertFileContent("public/index.html", "i18n: project theme4")
+				// Check i18n, the project's en.toml hides the themes'.
+				b.AssertFileContent("public/index.html", "i18n: project \n")
 
 				// Check static files
 				// TODO(bep) static files not currently part of the build b.AssertFileContent("public/nproject.txt", "TODO")
@@ -116,9 +118,9 @@ v2 = "v24"
 			{site2, "", func(b *sitesBuilder) {
 
 				// site2: theme2 theme1 themeStandalone
-				b.AssertFileContent("public/index.html", "nproject::[inner][other]project|[project][other]project|[theme][other]theme2|[theme1][other]theme1|[theme2][other]theme2|[themeStandalone][other]themeStandalone|")
-				b.AssertFileContent("public/index.html", "ntheme::[inner][other]theme2|[theme][other]theme2|[theme1][other]theme1|[theme2][other]theme2|[themeStandalone][other]themeStandalone|")
-				b.AssertFileContent("public/index.html", "i18n: project theme2")
+				b.AssertFileContent("public/index.html", "nproject::[inner][other]project|[project][other]project|\n")
+				b.AssertFileContent("public/index.html", "ntheme::[inner][other]theme2|[theme][other]theme2|[theme2][other]theme2|\n")
+				b.AssertFileContent("public/index.html", "i18n: project \n")
 				b.AssertFileContent("public/index.html", "partial ntheme: theme2")
 
 				// Params only set in themes
@@ -40,7 +40,10 @@ import (
 	"github.com/gohugoio/hugo/source"
 )
 
-var errSkipCyclicDir = errors.New("skip potential cyclic dir")
+var (
+	errSkipCyclicDir     = errors.New("skip potential cyclic dir")
+	errSkipBrokenSymlink = errors.New("skip broken symbolic link")
+)
 
 type capturer struct {
 	// To prevent symbolic link cycles: Visit same folder only once.
@@ -62,6 +65,10 @@ type capturer struct {
 
 	// Semaphore used to throttle the concurrent sub directory handling.
 	sem chan bool
+
+	// Whether to skip symbolic links to files that do not exist instead of
+	// failing the build.
+	skipBrokenSymlinks bool
 }
 
 func newCapturer(
@@ -105,7 +112,10 @@ func newCapturer(
 		logger:         logger,
 		contentChanges: contentChanges,
 		seen:           make(map[string]bool),
-		filenames:      filenames}
+		filenames:      filenames,
+
+		skipBrokenSymlinks: sourceSpec.Cfg.GetBool("skipBrokenSymlinks"),
+	}
 
 	return c
 }
@@ -175,7 +185,7 @@ func (c *capturer) capturePartial(filenames ...string) error {
 			}
 		default:
 			fi, err := c.resolveRealPath(resolvedFilename)
-			if os.IsNotExist(err) {
+			if os.IsNotExist(err) || err == errSkipBrokenSymlink {
 				// File has been deleted.
 				continue
 			}
@@ -557,11 +567,11 @@ func (c *capturer) readDir(dirname string) (pathLangFileFis, error) {
 
 		if !c.sourceSpec.IgnoreFile(fip.Filename()) {
 
-			err := c.resolveRealPathIn(fip)
+			fip, err := c.resolveRealPathIn(fip)
 
 			if err != nil {
 				// It may have been deleted in the meantime.
-				if err == errSkipCyclicDir || os.IsNotExist(err) {
+				if err == errSkipCyclicDir || err == errSkipBrokenSymlink || os.IsNotExist(err) {
 					continue
 				}
 				return nil, err
@@ -689,10 +699,13 @@ func (c *capturer) resolveRealPath(path string) (pathLangFileFi, error) {
 	if err != nil {
 		return nil, err
 	}
-	return fileInfo, c.resolveRealPathIn(fileInfo)
+	return c.resolveRealPathIn(fileInfo)
 }
 
-func (c *capturer) resolveRealPathIn(fileInfo pathLangFileFi) error {
+// resolveRealPathIn returns fileInfo, or for a symbolic link, a copy of it
+// with the os.FileInfo of the link's target. If the link cannot be resolved,
+// fileInfo is returned as is with the error.
+func (c *capturer) resolveRealPathIn(fileInfo pathLangFileFi) (pathLangFileFi, error) {
 
 	basePath := fileInfo.BaseDir()
 	path := fileInfo.Filename()
@@ -700,20 +713,29 @@ func (c *capturer) resolveRealPathIn(fileInfo pathLangFileFi) error {
 	realPath := path
 
 	if fileInfo.Mode()&os.ModeSymlink == os.ModeSymlink {
+		if c.skipBrokenSymlinks && hugofs.IsBrokenSymlink(hugofs.Os, path, fileInfo) {
+			c.logger.INFO.Printf("Broken symbolic link %q skipped.", path)
+			return fileInfo, errSkipBrokenSymlink
+		}
+
 		link, err := filepath.EvalSymlinks(path)
 		if err != nil {
-			return _errors.Wrapf(err, "Cannot read symbolic link %q, error was:", path)
+			return fileInfo, _errors.Wrapf(err, "Cannot read symbolic link %q, error was:", path)
 		}
 
 		// This is a file on the outside of any base fs, so we have to use the os package.
 		sfi, err := os.Stat(link)
 		if err != nil {
-			return _errors.Wrapf(err, "Cannot stat  %q, error was:", link)
+			return fileInfo, _errors.Wrapf(err, "Cannot stat  %q, error was:", link)
 		}
 
 		// TODO(bep) improve all of this.
 		if a, ok := fileInfo.(*hugofs.LanguageFileInfo); ok {
-			a.FileInfo = sfi
+			// Do not modify a, it may be shared with other users of the
+			// filesystem.
+			lfi := *a
+			lfi.FileInfo = sfi
+			fileInfo = &lfi
 		}
 
 		realPath = link
@@ -724,7 +746,7 @@ func (c *capturer) resolveRealPathIn(fileInfo pathLangFileFi) error {
 			// potential useful, but this implementation is both robust and simple:
 			// We stop at the first directory that we have seen before, e.g.
 			// /content/blog will only be processed once.
-			return errSkipCyclicDir
+			return fileInfo, errSkipCyclicDir
 		}
 
 		if c.contentChanges != nil {
@@ -761,7 +783,7 @@ func (c *capturer) resolveRealPathIn(fileInfo pathLangFileFi) error {
 		}
 	}
 
-	return nil
+	return fileInfo, nil
 }
 
 func (c *capturer) lstatIfPossible(path string) (pathLangFileFi, error) {
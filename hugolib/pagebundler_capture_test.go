@@ -121,6 +121,46 @@ C:
 	}
 }
 
+func TestPageBundlerCaptureResolveSymlinkCopy(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestPageBundlerCaptureResolveSymlinkCopy as os.Symlink needs administrator rights on Windows")
+	}
+
+	assert := require.New(t)
+	ps, clean, workDir := newTestBundleSymbolicSources(t)
+	sourceSpec := source.NewSourceSpec(ps, ps.BaseFs.Content.Fs)
+	defer clean()
+
+	c := newCapturer(loggers.NewErrorLogger(), sourceSpec, &storeFilenames{}, nil)
+
+	fi, err := c.lstatIfPossible(filepath.Join("a", "page_s.md"))
+	assert.NoError(err)
+	assert.True(fi.Mode()&os.ModeSymlink != 0)
+
+	resolved, err := c.resolveRealPathIn(fi)
+	assert.NoError(err)
+	assert.True(resolved.Mode().IsRegular())
+	assert.Equal(fi.Filename(), resolved.Filename())
+	assert.Equal(fi.Lang(), resolved.Lang())
+
+	// The original is left as is.
+	assert.True(fi.Mode()&os.ModeSymlink != 0)
+
+	// A broken link is returned as is with the error.
+	assert.NoError(os.Symlink(filepath.Join(workDir, "nope.md"), filepath.Join(workDir, "base", "a", "broken.md")))
+	fi, err = c.lstatIfPossible(filepath.Join("a", "broken.md"))
+	assert.NoError(err)
+	resolved, err = c.resolveRealPathIn(fi)
+	assert.Error(err)
+	assert.Equal(fi, resolved)
+
+	fileStore := &storeFilenames{}
+	contentChanges := &contentChangeMap{pathSpec: ps, symContent: make(map[string]map[string]bool)}
+	c = newCapturer(loggers.NewErrorLogger(), sourceSpec, fileStore, contentChanges, filepath.Join(workDir, "base", "a", "broken.md"))
+	assert.NoError(c.capture())
+	assert.Contains(fileStore.sortedStr(), "broken.md")
+}
+
 func TestPageBundlerCaptureBasic(t *testing.T) {
 	t.Parallel()
 
@@ -410,6 +410,33 @@ func TestPageBundlerSiteWitSymbolicLinksInContent(t *testing.T) {
 
 }
 
+func TestPageBundlerSiteWithBrokenSymbolicLinks(t *testing.T) {
+	if runtime.GOOS == "windows" && os.Getenv("CI") == "" {
+		t.Skip("Skip TestPageBundlerSiteWithBrokenSymbolicLinks as os.Symlink needs administrator rights on Windows")
+	}
+
+	for _, skip := range []bool{false, true} {
+		assert := require.New(t)
+		ps, clean, workDir := newTestBundleSymbolicSources(t)
+
+		assert.NoError(os.Symlink(filepath.Join(workDir, "nope.md"), filepath.Join(workDir, "base", "a", "broken.md")))
+		assert.NoError(os.Symlink(filepath.Join(workDir, "nope.html"), filepath.Join(workDir, "layouts", "_default", "broken.html")))
+
+		cfg := ps.Cfg
+		cfg.Set("skipBrokenSymlinks", skip)
+
+		s := buildSingleSiteExpected(t, false, !skip, deps.DepsCfg{Fs: ps.Fs, Cfg: cfg, Logger: loggers.NewErrorLogger()}, BuildCfg{})
+
+		if skip {
+			th := testHelper{s.Cfg, s.Fs, t}
+			assert.Equal(7, len(s.RegularPages()))
+			th.assertFileContent(filepath.FromSlash(workDir+"/public/a/page/index.html"), "TheContent")
+		}
+
+		clean()
+	}
+}
+
 func TestPageBundlerHeadless(t *testing.T) {
 	t.Parallel()
 
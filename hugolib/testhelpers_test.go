@@ -2,7 +2,6 @@ package hugolib
 
 import (
 	"io"
-	"io/ioutil"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -19,6 +18,7 @@ import (
 	"github.com/gohugoio/hugo/common/herrors"
 	"github.com/gohugoio/hugo/config"
 	"github.com/gohugoio/hugo/deps"
+	"github.com/gohugoio/hugo/htesting"
 	"github.com/gohugoio/hugo/resources/page"
 	"github.com/sanity-io/litter"
 	"github.com/spf13/afero"
@@ -95,17 +95,7 @@ func newTestSitesBuilder(t testing.TB) *sitesBuilder {
 }
 
 func createTempDir(prefix string) (string, func(), error) {
-	workDir, err := ioutil.TempDir("", prefix)
-	if err != nil {
-		return "", nil, err
-	}
-
-	if runtime.GOOS == "darwin" && !strings.HasPrefix(workDir, "/private") {
-		// To get the entry folder in line with the rest. This its a little bit
-		// mysterious, but so be it.
-		workDir = "/private" + workDir
-	}
-	return workDir, func() { os.RemoveAll(workDir) }, nil
+	return htesting.CreateTempDir(hugofs.Os, prefix)
 }
 
 func (s *sitesBuilder) Running() *sitesBuilder {
@@ -163,7 +163,8 @@ func TestImagePermalinkPublishOrder(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 
 			assert := require.New(t)
-			spec := newTestResourceOsFs(assert)
+			spec, clean := newTestResourceOsFs(assert)
+			defer clean()
 
 			check1 := func(img *Image) {
 				resizedLink := "/a/sunset_hu59e56ffff1bc1d8d122b1403d34e039f_90587_100x50_resize_q75_box.jpg"
@@ -202,7 +203,8 @@ func TestImageTransformConcurrent(t *testing.T) {
 
 	assert := require.New(t)
 
-	spec := newTestResourceOsFs(assert)
+	spec, clean := newTestResourceOsFs(assert)
+	defer clean()
 
 	image := fetchImageForSpec(spec, assert, "sunset.jpg")
 
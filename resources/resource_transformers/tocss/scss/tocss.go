@@ -136,7 +136,10 @@ func (t *toCSSTransformation) Transform(ctx *resources.ResourceTransformationCtx
 	}
 
 	if options.from.EnableSourceMap && res.SourceMapContent != "" {
-		sourcePath := t.c.sfs.RealFilename(ctx.SourcePath)
+		sourcePath, found := t.c.sfs.RealFilename(ctx.SourcePath)
+		if !found {
+			sourcePath = ctx.SourcePath
+		}
 
 		if strings.HasPrefix(sourcePath, t.c.rs.WorkingDir) {
 			sourcePath = strings.TrimPrefix(sourcePath, t.c.rs.WorkingDir+helpers.FilePathSeparator)
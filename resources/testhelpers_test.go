@@ -7,13 +7,11 @@ import (
 	"fmt"
 	"image"
 	"io"
-	"io/ioutil"
 	"os"
-	"runtime"
-	"strings"
 
 	"github.com/gohugoio/hugo/cache/filecache"
 	"github.com/gohugoio/hugo/helpers"
+	"github.com/gohugoio/hugo/htesting"
 	"github.com/gohugoio/hugo/hugofs"
 	"github.com/gohugoio/hugo/media"
 	"github.com/gohugoio/hugo/output"
@@ -70,17 +68,12 @@ func newTargetPaths(link string) func() page.TargetPaths {
 	}
 }
 
-func newTestResourceOsFs(assert *require.Assertions) *Spec {
+func newTestResourceOsFs(assert *require.Assertions) (*Spec, func()) {
 	cfg := viper.New()
 	cfg.Set("baseURL", "https://example.com")
 
-	workDir, _ := ioutil.TempDir("", "hugores")
-
-	if runtime.GOOS == "darwin" && !strings.HasPrefix(workDir, "/private") {
-		// To get the entry folder in line with the rest. This its a little bit
-		// mysterious, but so be it.
-		workDir = "/private" + workDir
-	}
+	workDir, clean, err := htesting.CreateTempDir(hugofs.Os, "hugores")
+	assert.NoError(err)
 
 	cfg.Set("workingDir", workDir)
 	cfg.Set("resourceDir", "resources")
@@ -103,7 +96,7 @@ func newTestResourceOsFs(assert *require.Assertions) *Spec {
 
 	spec, err := NewSpec(s, filecaches, nil, output.DefaultFormats, media.DefaultTypes)
 	assert.NoError(err)
-	return spec
+	return spec, clean
 
 }
 
@@ -711,7 +711,14 @@ func (t *templateHandler) RebuildClone() {
 
 func (t *templateHandler) loadTemplates(prefix string) error {
 
+	skipBrokenSymlinks := t.Cfg.GetBool("skipBrokenSymlinks")
+
 	walker := func(path string, fi os.FileInfo, err error) error {
+		if skipBrokenSymlinks && err == nil && hugofs.IsBrokenSymlink(t.Layouts.Fs, path, fi) {
+			t.Log.INFO.Printf("Broken symbolic link %q skipped.", path)
+			return nil
+		}
+
 		if err != nil || fi.IsDir() {
 			return err
 		}